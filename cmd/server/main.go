@@ -64,8 +64,6 @@ func setKiroIncognitoMode(cfg *config.Config, useIncognito, noIncognito bool) {
 // It parses command-line flags, loads configuration, and starts the appropriate
 // service based on the provided flags (login, codex-login, or server mode).
 func main() {
-	fmt.Printf("CLIProxyAPI Version: %s, Commit: %s, BuiltAt: %s\n", buildinfo.Version, buildinfo.Commit, buildinfo.BuildDate)
-
 	// Command-line flags to control the application's behavior.
 	var login bool
 	var codexLogin bool
@@ -88,6 +86,7 @@ func main() {
 	var password string
 	var noIncognito bool
 	var useIncognito bool
+	var usageReport cmd.UsageReportOptions
 
 	// Define command-line flags for different operation modes.
 	flag.BoolVar(&login, "login", false, "Login Google Account")
@@ -111,6 +110,12 @@ func main() {
 	flag.StringVar(&configPath, "config", DefaultConfigPath, "Configure File Path")
 	flag.StringVar(&vertexImport, "vertex-import", "", "Import Vertex service account key JSON file")
 	flag.StringVar(&password, "password", "", "")
+	flag.StringVar(&usageReport.Dir, "usage-report", "", "Print a usage report from a usage-store directory and exit")
+	flag.StringVar(&usageReport.From, "usage-from", "", "Start of the usage report (YYYY-MM-DD or RFC3339)")
+	flag.StringVar(&usageReport.To, "usage-to", "", "End of the usage report, inclusive (YYYY-MM-DD or RFC3339)")
+	flag.StringVar(&usageReport.GroupBy, "usage-group-by", "model", "Usage report grouping: model, api_key, source, auth_index or instance_id")
+	flag.StringVar(&usageReport.Format, "usage-format", "table", "Usage report format: table, json or csv")
+	flag.IntVar(&usageReport.Top, "usage-top", 0, "Limit the usage report to the N heaviest rows")
 
 	flag.CommandLine.Usage = func() {
 		out := flag.CommandLine.Output()
@@ -142,6 +147,19 @@ func main() {
 	// Parse the command-line flags.
 	flag.Parse()
 
+	// The usage report only reads the usage store, so it needs neither a config nor a running proxy.
+	if usageReport.Dir != "" {
+		// Keep warnings about skipped lines out of machine-readable output.
+		log.SetOutput(os.Stderr)
+		if errReport := cmd.RunUsageReport(os.Stdout, usageReport); errReport != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "usage report failed: %v\n", errReport)
+			os.Exit(1)
+		}
+		return
+	}
+
+	fmt.Printf("CLIProxyAPI Version: %s, Commit: %s, BuiltAt: %s\n", buildinfo.Version, buildinfo.Commit, buildinfo.BuildDate)
+
 	// Core application variables.
 	var err error
 	var cfg *config.Config
@@ -434,7 +452,20 @@ func main() {
 		}
 	}
 	usage.SetStatisticsEnabled(cfg.UsageStatisticsEnabled)
+	usage.SetPricing(cfg.ModelPricing)
+	usage.SetUsageAlerts(cfg.UsageAlerts)
+	if err = usage.SetKeyAliases(cfg.UsageKeyAliases); err != nil {
+		log.Errorf("usage-key-aliases ignored: %v", err)
+	}
+	if err = usage.SetClientMetadataMode(cfg.UsageClientMetadata); err != nil {
+		log.Errorf("usage-client-metadata ignored: %v", err)
+	}
+	usage.SetStatisticsHorizon(time.Duration(cfg.UsageStatisticsHorizonDays) * 24 * time.Hour)
 	coreauth.SetQuotaCooldownDisabled(cfg.DisableCooling)
+	usage.SetCredentialHealth(cfg.CredentialHealth)
+	if cfg.CredentialHealth.Enabled {
+		coreauth.SetHealthChecker(usage.CredentialUnhealthy)
+	}
 
 	if err = logging.ConfigureLogOutput(cfg); err != nil {
 		log.Errorf("failed to configure log output: %v", err)
@@ -452,6 +483,16 @@ func main() {
 	} else {
 		cfg.AuthDir = resolvedAuthDir
 	}
+	if instanceID, errInstance := usage.ResolveInstanceID(cfg.UsageInstanceID, cfg.AuthDir); errInstance != nil {
+		log.Warnf("usage instance id unavailable: %v", errInstance)
+	} else {
+		usage.SetInstanceID(instanceID)
+	}
+	if secret, errSecret := usage.ResolveKeySecret(cfg.AuthDir); errSecret != nil {
+		log.Warnf("usage key secret unavailable, pseudonyms will change on restart: %v", errSecret)
+	} else {
+		usage.SetKeySecret(secret)
+	}
 	managementasset.SetCurrentConfig(cfg)
 
 	// Create login options to be used in authentication flows.
@@ -246,11 +246,17 @@ func (h *Handler) Middleware() gin.HandlerFunc {
 		}
 
 		if secretHash == "" || bcrypt.CompareHashAndPassword([]byte(secretHash), []byte(provided)) != nil {
-			if !localClient {
-				fail()
+			token := matchManagementToken(cfg, provided)
+			if token == nil {
+				if !localClient {
+					fail()
+				}
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid management key"})
+				return
+			}
+			if !authorizeManagementToken(c, token) {
+				return
 			}
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid management key"})
-			return
 		}
 
 		if !localClient {
@@ -0,0 +1,92 @@
+package management
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
+)
+
+// GetUsageKeyAliases lists the configured API key aliases.
+func (h *Handler) GetUsageKeyAliases(c *gin.Context) {
+	aliases := make([]config.UsageKeyAlias, 0)
+	if h.cfg != nil {
+		aliases = append(aliases, h.cfg.UsageKeyAliases...)
+	}
+	c.JSON(http.StatusOK, gin.H{"usage-key-aliases": aliases})
+}
+
+// PutUsageKeyAlias links a rotated API key to its replacement, replacing any existing alias
+// for the same key. Aliases that would form a cycle are rejected.
+func (h *Handler) PutUsageKeyAlias(c *gin.Context) {
+	if h.cfg == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "configuration unavailable"})
+		return
+	}
+	var body config.UsageKeyAlias
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid body"})
+		return
+	}
+	body.From = strings.TrimSpace(body.From)
+	body.To = strings.TrimSpace(body.To)
+	if body.From == "" || body.To == "" || body.From == body.To {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from and to must be different non-empty keys"})
+		return
+	}
+
+	aliases := make([]config.UsageKeyAlias, 0, len(h.cfg.UsageKeyAliases)+1)
+	for _, alias := range h.cfg.UsageKeyAliases {
+		if alias.From != body.From {
+			aliases = append(aliases, alias)
+		}
+	}
+	aliases = append(aliases, body)
+	if err := usage.SetKeyAliases(aliases); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	h.cfg.UsageKeyAliases = aliases
+	h.persist(c)
+}
+
+// DeleteUsageKeyAlias removes the alias for the key named by the from query parameter.
+func (h *Handler) DeleteUsageKeyAlias(c *gin.Context) {
+	if h.cfg == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "configuration unavailable"})
+		return
+	}
+	from := strings.TrimSpace(c.Query("from"))
+	if from == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "missing from"})
+		return
+	}
+	aliases := h.cfg.UsageKeyAliases
+	for i := range aliases {
+		if aliases[i].From != from {
+			continue
+		}
+		remaining := append(aliases[:i:i], aliases[i+1:]...)
+		if err := usage.SetKeyAliases(remaining); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		h.cfg.UsageKeyAliases = remaining
+		h.persist(c)
+		return
+	}
+	c.JSON(http.StatusNotFound, gin.H{"error": "alias not found"})
+}
+
+// MergeUsageKeyAliases moves usage recorded under aliased keys to the keys they alias, so the
+// history stays merged even if the alias is later removed. The move is written to the usage
+// store when one is attached.
+func (h *Handler) MergeUsageKeyAliases(c *gin.Context) {
+	if h == nil || h.usageStats == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "usage statistics unavailable"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"merged_keys": h.usageStats.MergeAliasedKeys()})
+}
@@ -0,0 +1,156 @@
+package management
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Management token scopes.
+const (
+	ScopeUsageRead   = "usage:read"
+	ScopeUsageExport = "usage:export"
+	ScopeUsageAdmin  = "usage:admin"
+	ScopeKeysAdmin   = "keys:admin"
+)
+
+// managementRouteScopes maps "METHOD route" to the scope a scoped token needs to call it.
+// Routes missing from the table are reserved for the secret key.
+var managementRouteScopes = map[string]string{
+	"GET /v0/management/usage":                      ScopeUsageRead,
+	"GET /v0/management/usage/system":               ScopeUsageRead,
+	"GET /v0/management/usage/trend":                ScopeUsageRead,
+	"GET /v0/management/usage/quotas":               ScopeUsageRead,
+	"GET /v0/management/usage/summary":              ScopeUsageRead,
+	"GET /v0/management/usage/top":                  ScopeUsageRead,
+	"GET /v0/management/usage/series":               ScopeUsageRead,
+	"GET /v0/management/usage/credentials":          ScopeUsageRead,
+	"GET /v0/management/usage/credentials/health":   ScopeUsageRead,
+	"GET /v0/management/usage/explore":              ScopeUsageRead,
+	"GET /v0/management/usage/records":              ScopeUsageRead,
+	"GET /v0/management/usage/schema":               ScopeUsageRead,
+	"GET /v0/management/usage/export":               ScopeUsageExport,
+	"POST /v0/management/usage/import":              ScopeUsageAdmin,
+	"POST /v0/management/usage/import/csv":          ScopeUsageAdmin,
+	"POST /v0/management/usage/costs/recompute":     ScopeUsageAdmin,
+	"POST /v0/management/usage/reset":               ScopeUsageAdmin,
+	"GET /v0/management/usage/store":                ScopeUsageAdmin,
+	"PUT /v0/management/usage/store":                ScopeUsageAdmin,
+	"GET /v0/management/usage/key-aliases":          ScopeUsageAdmin,
+	"PUT /v0/management/usage/key-aliases":          ScopeUsageAdmin,
+	"DELETE /v0/management/usage/key-aliases":       ScopeUsageAdmin,
+	"POST /v0/management/usage/key-aliases/merge":   ScopeUsageAdmin,
+	"GET /v0/management/usage-statistics-enabled":   ScopeUsageAdmin,
+	"PUT /v0/management/usage-statistics-enabled":   ScopeUsageAdmin,
+	"PATCH /v0/management/usage-statistics-enabled": ScopeUsageAdmin,
+	"GET /v0/management/api-keys":                   ScopeKeysAdmin,
+	"PUT /v0/management/api-keys":                   ScopeKeysAdmin,
+	"PATCH /v0/management/api-keys":                 ScopeKeysAdmin,
+	"DELETE /v0/management/api-keys":                ScopeKeysAdmin,
+}
+
+// managementTokenKey is the context key holding the scoped token that authenticated a request.
+const managementTokenKey = "managementToken"
+
+// scopeImplications lists the scopes granted implicitly by a broader scope.
+var scopeImplications = map[string][]string{
+	ScopeUsageAdmin: {ScopeUsageRead, ScopeUsageExport},
+}
+
+// matchManagementToken returns the scoped token whose key matches provided, if any.
+func matchManagementToken(cfg *config.Config, provided string) *config.ManagementToken {
+	if cfg == nil {
+		return nil
+	}
+	for i := range cfg.RemoteManagement.Tokens {
+		token := &cfg.RemoteManagement.Tokens[i]
+		if bcrypt.CompareHashAndPassword([]byte(token.Key), []byte(provided)) == nil {
+			return token
+		}
+	}
+	return nil
+}
+
+// authorizeManagementToken checks that token grants the scope required by the current
+// route, aborting with 403 when it does not.
+func authorizeManagementToken(c *gin.Context, token *config.ManagementToken) bool {
+	required, ok := managementRouteScopes[c.Request.Method+" "+c.FullPath()]
+	if !ok {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "endpoint requires the management secret key"})
+		return false
+	}
+	if !tokenHasScope(token.Scopes, required) {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "missing scope " + required})
+		return false
+	}
+	c.Set("managementPrincipal", token.Name)
+	c.Set(managementTokenKey, token)
+	if c.Request.Method != http.MethodGet {
+		log.Infof("management: %s %s by token %q", c.Request.Method, c.FullPath(), token.Name)
+	}
+	return true
+}
+
+// requestManagementToken returns the scoped token that authenticated the request, or nil when
+// the caller used the secret key or the local password.
+func requestManagementToken(c *gin.Context) *config.ManagementToken {
+	value, ok := c.Get(managementTokenKey)
+	if !ok {
+		return nil
+	}
+	token, _ := value.(*config.ManagementToken)
+	return token
+}
+
+// canViewAPIKeys reports whether raw client API keys may be returned to the caller: the
+// secret key and tokens with an admin scope may, read-only tokens only see pseudonyms.
+func canViewAPIKeys(c *gin.Context) bool {
+	token := requestManagementToken(c)
+	return token == nil || tokenHasScope(token.Scopes, ScopeUsageAdmin) || tokenHasScope(token.Scopes, ScopeKeysAdmin)
+}
+
+func tokenHasScope(granted []string, required string) bool {
+	for _, scope := range granted {
+		if scope == required {
+			return true
+		}
+		for _, implied := range scopeImplications[scope] {
+			if implied == required {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// GetManagementTokens lists the configured scoped management tokens without their keys.
+func (h *Handler) GetManagementTokens(c *gin.Context) {
+	tokens := make([]config.ManagementToken, 0)
+	if h.cfg != nil {
+		tokens = append(tokens, h.cfg.RemoteManagement.Tokens...)
+	}
+	c.JSON(http.StatusOK, gin.H{"tokens": tokens})
+}
+
+// DeleteManagementToken revokes the scoped management token named by the name query parameter.
+func (h *Handler) DeleteManagementToken(c *gin.Context) {
+	name := strings.TrimSpace(c.Query("name"))
+	if name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "missing name"})
+		return
+	}
+	tokens := h.cfg.RemoteManagement.Tokens
+	for i := range tokens {
+		if tokens[i].Name != name {
+			continue
+		}
+		h.cfg.RemoteManagement.Tokens = append(tokens[:i:i], tokens[i+1:]...)
+		h.persist(c)
+		return
+	}
+	c.JSON(http.StatusNotFound, gin.H{"error": "token not found"})
+}
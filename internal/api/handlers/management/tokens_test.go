@@ -0,0 +1,160 @@
+package management
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestMiddlewareEnforcesManagementTokenScopes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	hash := func(value string) string {
+		out, err := bcrypt.GenerateFromPassword([]byte(value), bcrypt.MinCost)
+		if err != nil {
+			t.Fatalf("hash: %v", err)
+		}
+		return string(out)
+	}
+	cfg := &config.Config{}
+	cfg.RemoteManagement.AllowRemote = true
+	cfg.RemoteManagement.SecretKey = hash("admin-secret")
+	cfg.RemoteManagement.Tokens = []config.ManagementToken{
+		{Name: "finance", Key: hash("finance-token"), Scopes: []string{ScopeUsageRead}},
+		{Name: "ops", Key: hash("ops-token"), Scopes: []string{ScopeUsageAdmin}},
+	}
+	h := &Handler{cfg: cfg, failedAttempts: make(map[string]*attemptInfo)}
+
+	engine := gin.New()
+	mgmt := engine.Group("/v0/management")
+	mgmt.Use(h.Middleware())
+	ok := func(c *gin.Context) { c.Status(http.StatusNoContent) }
+	mgmt.GET("/usage", ok)
+	mgmt.GET("/usage/export", ok)
+	mgmt.GET("/config", ok)
+
+	cases := []struct {
+		key, path string
+		status    int
+		errPart   string
+	}{
+		{"finance-token", "/v0/management/usage", http.StatusNoContent, ""},
+		{"finance-token", "/v0/management/usage/export", http.StatusForbidden, "missing scope usage:export"},
+		{"finance-token", "/v0/management/config", http.StatusForbidden, "secret key"},
+		{"ops-token", "/v0/management/usage/export", http.StatusNoContent, ""},
+		{"admin-secret", "/v0/management/config", http.StatusNoContent, ""},
+		{"wrong", "/v0/management/usage", http.StatusUnauthorized, "invalid management key"},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+		req.Header.Set("Authorization", "Bearer "+tc.key)
+		rec := httptest.NewRecorder()
+		engine.ServeHTTP(rec, req)
+		if rec.Code != tc.status {
+			t.Fatalf("%s %s: status = %d, want %d (%s)", tc.key, tc.path, rec.Code, tc.status, rec.Body.String())
+		}
+		if tc.errPart != "" && !strings.Contains(rec.Body.String(), tc.errPart) {
+			t.Fatalf("%s %s: body %s does not mention %q", tc.key, tc.path, rec.Body.String(), tc.errPart)
+		}
+	}
+}
+
+func TestReadOnlyTokenSeesRedactedAPIKeys(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	hash := func(value string) string {
+		out, err := bcrypt.GenerateFromPassword([]byte(value), bcrypt.MinCost)
+		if err != nil {
+			t.Fatalf("hash: %v", err)
+		}
+		return string(out)
+	}
+	cfg := &config.Config{}
+	cfg.RemoteManagement.AllowRemote = true
+	cfg.RemoteManagement.SecretKey = hash("admin-secret")
+	cfg.RemoteManagement.Tokens = []config.ManagementToken{
+		{Name: "dashboard", Key: hash("read-token"), Scopes: []string{ScopeUsageRead, ScopeUsageExport}},
+		{Name: "ops", Key: hash("ops-token"), Scopes: []string{ScopeUsageAdmin}},
+	}
+	cfg.UsageQuotas = []config.UsageQuota{{APIKey: "sk-customer-secret", MaxRequestsPerDay: 10}}
+	stats := usage.NewRequestStatistics()
+	stats.Record(context.Background(), coreusage.Record{APIKey: "sk-customer-secret", Model: "m", RequestedAt: time.Now()})
+	h := &Handler{cfg: cfg, failedAttempts: make(map[string]*attemptInfo), usageStats: stats}
+
+	engine := gin.New()
+	mgmt := engine.Group("/v0/management")
+	mgmt.Use(h.Middleware())
+	mgmt.GET("/usage", h.GetUsageStatistics)
+	mgmt.GET("/usage/records", h.GetUsageRecords)
+	mgmt.GET("/usage/summary", h.GetUsageSummary)
+	mgmt.GET("/usage/export", h.ExportUsageStatistics)
+	mgmt.GET("/usage/quotas", h.GetUsageQuotas)
+
+	paths := []string{
+		"/v0/management/usage",
+		"/v0/management/usage/records",
+		"/v0/management/usage/summary?group_by=api_key",
+		"/v0/management/usage/export?format=csv",
+		"/v0/management/usage/quotas",
+	}
+	for _, path := range paths {
+		for _, tc := range []struct {
+			key string
+			raw bool
+		}{{"read-token", false}, {"ops-token", true}, {"admin-secret", true}} {
+			req := httptest.NewRequest(http.MethodGet, path, nil)
+			req.Header.Set("Authorization", "Bearer "+tc.key)
+			rec := httptest.NewRecorder()
+			engine.ServeHTTP(rec, req)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("%s %s: status = %d (%s)", tc.key, path, rec.Code, rec.Body.String())
+			}
+			body := rec.Body.String()
+			if got := strings.Contains(body, "sk-customer-secret"); got != tc.raw {
+				t.Fatalf("%s %s: raw key visible = %t, want %t: %s", tc.key, path, got, tc.raw, body)
+			}
+			if !tc.raw && !strings.Contains(body, usage.RedactAPIKey("sk-customer-secret")) {
+				t.Fatalf("%s %s: body lacks the redacted key: %s", tc.key, path, body)
+			}
+		}
+	}
+}
+
+func TestScopedTokenCannotMoveUsageStore(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	hash := func(value string) string {
+		out, err := bcrypt.GenerateFromPassword([]byte(value), bcrypt.MinCost)
+		if err != nil {
+			t.Fatalf("hash: %v", err)
+		}
+		return string(out)
+	}
+	cfg := &config.Config{}
+	cfg.RemoteManagement.AllowRemote = true
+	cfg.RemoteManagement.SecretKey = hash("admin-secret")
+	cfg.RemoteManagement.Tokens = []config.ManagementToken{{Name: "ops", Key: hash("ops-token"), Scopes: []string{ScopeUsageAdmin}}}
+	h := &Handler{cfg: cfg, failedAttempts: make(map[string]*attemptInfo)}
+
+	engine := gin.New()
+	mgmt := engine.Group("/v0/management")
+	mgmt.Use(h.Middleware())
+	mgmt.PUT("/usage/store", h.PutUsageStore)
+
+	req := httptest.NewRequest(http.MethodPut, "/v0/management/usage/store", strings.NewReader(`{"dir":"/tmp/elsewhere"}`))
+	req.Header.Set("Authorization", "Bearer ops-token")
+	rec := httptest.NewRecorder()
+	engine.ServeHTTP(rec, req)
+	if rec.Code != http.StatusForbidden || !strings.Contains(rec.Body.String(), "secret key") {
+		t.Fatalf("status = %d, body = %s; want 403 requiring the secret key", rec.Code, rec.Body.String())
+	}
+	if cfg.UsageStore.Dir != "" {
+		t.Fatalf("config dir changed to %q", cfg.UsageStore.Dir)
+	}
+}
@@ -2,17 +2,22 @@ package management
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
 )
 
 type usageExportPayload struct {
-	Version    int                      `json:"version"`
-	ExportedAt time.Time                `json:"exported_at"`
-	Usage      usage.StatisticsSnapshot `json:"usage"`
+	Version       int                      `json:"version"`
+	SchemaVersion int                      `json:"schema_version"`
+	ExportedAt    time.Time                `json:"exported_at"`
+	Usage         usage.StatisticsSnapshot `json:"usage"`
 }
 
 type usageImportPayload struct {
@@ -22,26 +27,414 @@ type usageImportPayload struct {
 
 // GetUsageStatistics returns the in-memory request statistics snapshot.
 func (h *Handler) GetUsageStatistics(c *gin.Context) {
-	var snapshot usage.StatisticsSnapshot
-	if h != nil && h.usageStats != nil {
-		snapshot = h.usageStats.Snapshot()
-	}
+	snapshot := h.usageSnapshot(c)
 	c.JSON(http.StatusOK, gin.H{
 		"usage":           snapshot,
 		"failed_requests": snapshot.FailureCount,
 	})
 }
 
+// GetSystemUsageStatistics returns usage recorded for requests the proxy issued on its own
+// behalf (model refresh, token refresh, probes). These are excluded from GetUsageStatistics.
+func (h *Handler) GetSystemUsageStatistics(c *gin.Context) {
+	var snapshot usage.SystemSnapshot
+	if h != nil && h.usageStats != nil {
+		snapshot = h.usageStats.SystemSnapshot()
+	}
+	c.JSON(http.StatusOK, gin.H{"system": snapshot})
+}
+
+// GetUsageTrend compares today's usage so far with the same portion of yesterday and of
+// the same weekday last week. The optional timezone query parameter selects the day
+// boundaries (default: server local time).
+func (h *Handler) GetUsageTrend(c *gin.Context) {
+	location, ok := usageTimezone(c, time.Local)
+	if !ok {
+		return
+	}
+	var stats *usage.RequestStatistics
+	if h != nil {
+		stats = h.usageStats
+	}
+	c.JSON(http.StatusOK, gin.H{"trend": stats.TrendSummary(time.Now(), location)})
+}
+
+// GetUsageQuotas reports current consumption for every configured per-key usage quota.
+func (h *Handler) GetUsageQuotas(c *gin.Context) {
+	type quotaStatus struct {
+		Quota     config.UsageQuota     `json:"quota"`
+		Usage     usage.QuotaUsage      `json:"usage"`
+		Violation *usage.QuotaViolation `json:"violation,omitempty"`
+	}
+	quotas := make([]quotaStatus, 0)
+	if h != nil && h.cfg != nil {
+		now := time.Now()
+		rawKeys := canViewAPIKeys(c)
+		for _, quota := range h.cfg.UsageQuotas {
+			used := h.usageStats.QuotaUsage(quota.APIKey, now)
+			violation := usage.CheckQuota(quota, used, now)
+			if !rawKeys {
+				quota.APIKey = usage.RedactAPIKey(quota.APIKey)
+			}
+			quotas = append(quotas, quotaStatus{
+				Quota:     quota,
+				Usage:     used,
+				Violation: violation,
+			})
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"quotas": quotas})
+}
+
 // ExportUsageStatistics returns a complete usage snapshot for backup/migration.
+// With format=csv or format=jsonl the individual records are exported instead, optionally
+// limited by RFC3339 from/to query parameters; both start with a line carrying the schema
+// version.
 func (h *Handler) ExportUsageStatistics(c *gin.Context) {
-	var snapshot usage.StatisticsSnapshot
-	if h != nil && h.usageStats != nil {
-		snapshot = h.usageStats.Snapshot()
+	switch format := strings.ToLower(strings.TrimSpace(c.Query("format"))); format {
+	case "", "json":
+	case "csv", "jsonl", "ndjson":
+		h.exportUsageRecords(c, format)
+		return
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unsupported format"})
+		return
 	}
+
+	snapshot := h.usageSnapshot(c)
 	c.JSON(http.StatusOK, usageExportPayload{
-		Version:    1,
-		ExportedAt: time.Now().UTC(),
-		Usage:      snapshot,
+		Version:       1,
+		SchemaVersion: usage.SchemaVersion,
+		ExportedAt:    time.Now().UTC(),
+		Usage:         snapshot,
+	})
+}
+
+func (h *Handler) exportUsageRecords(c *gin.Context, format string) {
+	from, to, ok := usageTimeRange(c)
+	if !ok {
+		return
+	}
+
+	records, ok := h.usageRecords(c, from, to)
+	if !ok {
+		return
+	}
+	filename := "usage-" + time.Now().UTC().Format("20060102T150405Z")
+	var err error
+	if format == "csv" {
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		c.Header("Content-Disposition", `attachment; filename="`+filename+`.csv"`)
+		c.Status(http.StatusOK)
+		err = usage.WriteRecordsCSV(c.Writer, records)
+	} else {
+		c.Header("Content-Type", "application/x-ndjson")
+		c.Header("Content-Disposition", `attachment; filename="`+filename+`.jsonl"`)
+		c.Status(http.StatusOK)
+		err = usage.WriteRecordsJSONL(c.Writer, records)
+	}
+	if err != nil {
+		_ = c.Error(err)
+	}
+}
+
+// GetUsageSummary aggregates recorded requests between the optional RFC3339 from/to
+// query parameters, grouped by model (default), api_key, source, auth_index or instance_id.
+func (h *Handler) GetUsageSummary(c *gin.Context) {
+	from, to, ok := usageTimeRange(c)
+	if !ok {
+		return
+	}
+	groupBy := strings.TrimSpace(c.DefaultQuery("group_by", "model"))
+
+	records, ok := h.usageRecords(c, from, to)
+	if !ok {
+		return
+	}
+	rows, err := usage.Summarize(records, groupBy)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"from":     from,
+		"to":       to,
+		"group_by": groupBy,
+		"groups":   rows,
+	})
+}
+
+// GetUsageTop returns the n (default 10, max 1000) heaviest groups by total tokens between
+// the optional RFC3339 from/to query parameters. dimension selects the grouping: model
+// (default), api_key, source or auth_index.
+func (h *Handler) GetUsageTop(c *gin.Context) {
+	from, to, ok := usageTimeRange(c)
+	if !ok {
+		return
+	}
+	n := 10
+	if raw := strings.TrimSpace(c.Query("n")); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed <= 0 || parsed > 1000 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid n: expected 1-1000"})
+			return
+		}
+		n = parsed
+	}
+	dimension := strings.TrimSpace(c.DefaultQuery("dimension", "model"))
+
+	records, ok := h.usageRecords(c, from, to)
+	if !ok {
+		return
+	}
+	rows, err := usage.TopUsage(records, dimension, n)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"from":      from,
+		"to":        to,
+		"dimension": dimension,
+		"top":       rows,
+	})
+}
+
+// GetUsageSeries returns usage bucketed by hour, day or week between the optional RFC3339
+// from/to query parameters. group_by splits the series by model, api_key or instance_id and
+// zero_fill=true emits empty buckets so charts get a continuous axis.
+func (h *Handler) GetUsageSeries(c *gin.Context) {
+	from, to, ok := usageTimeRange(c)
+	if !ok {
+		return
+	}
+	location, ok := usageTimezone(c, time.UTC)
+	if !ok {
+		return
+	}
+	opts := usage.SeriesOptions{
+		Bucket:   strings.TrimSpace(c.DefaultQuery("bucket", "day")),
+		GroupBy:  strings.TrimSpace(c.Query("group_by")),
+		From:     from,
+		To:       to,
+		Location: location,
+	}
+	if raw := strings.TrimSpace(c.Query("zero_fill")); raw != "" {
+		zeroFill, err := strconv.ParseBool(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid zero_fill"})
+			return
+		}
+		opts.ZeroFill = zeroFill
+	}
+
+	records, ok := h.usageRecords(c, from, to)
+	if !ok {
+		return
+	}
+	series, err := usage.Series(records, opts)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"from":     from,
+		"to":       to,
+		"bucket":   opts.Bucket,
+		"timezone": location.String(),
+		"series":   series,
+	})
+}
+
+// GetUsageByAuth breaks usage down per upstream credential (auth_index and source) between
+// the optional RFC3339 from/to query parameters.
+func (h *Handler) GetUsageByAuth(c *gin.Context) {
+	from, to, ok := usageTimeRange(c)
+	if !ok {
+		return
+	}
+	records, ok := h.usageRecords(c, from, to)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"from":        from,
+		"to":          to,
+		"credentials": usage.SummarizeByAuth(records),
+	})
+}
+
+// GetUsageCredentialHealth reports recent request and failure counts per credential and model,
+// flagged against the credential health thresholds. window overrides the configured window.
+func (h *Handler) GetUsageCredentialHealth(c *gin.Context) {
+	var window time.Duration
+	if raw := strings.TrimSpace(c.Query("window")); raw != "" {
+		parsed, err := time.ParseDuration(raw)
+		if err != nil || parsed <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid window"})
+			return
+		}
+		window = parsed
+	}
+	policy := usage.CredentialHealthPolicy()
+	if window > 0 {
+		policy.Window = window.String()
+	}
+	var rates map[string]usage.AuthHealth
+	if h != nil && h.usageStats != nil {
+		rates = h.usageStats.FailureRates(window)
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"policy":      policy,
+		"credentials": rates,
+	})
+}
+
+// GetUsageExplore returns one level of a cost drill-down. Query parameters:
+//   - path: comma-separated dimensions to drill along (provider, api_key, model, source,
+//     auth_index, day)
+//   - node: repeated, the values selected so far along path
+//   - from/to: RFC3339 bounds on request start time; to defaults to now and is returned as as_of
+//   - limit (default 20, max 200) and offset page through the children
+//   - timezone: IANA zone for day values (default UTC)
+//
+// Clients pass as_of back as to when expanding children. Streams finishing after as_of and
+// late token updates can still make children add up to more than their parent.
+func (h *Handler) GetUsageExplore(c *gin.Context) {
+	from, to, ok := usageTimeRange(c)
+	if !ok {
+		return
+	}
+	if to.IsZero() {
+		to = time.Now().UTC().Truncate(time.Second)
+	}
+	location, ok := usageTimezone(c, time.UTC)
+	if !ok {
+		return
+	}
+	var path []string
+	for _, dimension := range strings.Split(c.Query("path"), ",") {
+		if dimension = strings.TrimSpace(dimension); dimension != "" {
+			path = append(path, dimension)
+		}
+	}
+	limit, offset := 20, 0
+	if raw := strings.TrimSpace(c.Query("limit")); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed <= 0 || parsed > 200 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid limit: expected 1-200"})
+			return
+		}
+		limit = parsed
+	}
+	if raw := strings.TrimSpace(c.Query("offset")); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid offset"})
+			return
+		}
+		offset = parsed
+	}
+
+	records, ok := h.usageRecords(c, from, to)
+	if !ok {
+		return
+	}
+	exploration, err := usage.Explore(records, path, c.QueryArray("node"), location, limit, offset)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"from":    from,
+		"as_of":   to,
+		"explore": exploration,
+	})
+}
+
+// GetUsageRecords returns a page of recorded requests ordered by timestamp, API key, model
+// and request ID. Query parameters: from/to (RFC3339), limit (default 100, max 1000) and offset.
+// next_offset is present while more records remain.
+func (h *Handler) GetUsageRecords(c *gin.Context) {
+	from, to, ok := usageTimeRange(c)
+	if !ok {
+		return
+	}
+	limit, offset := 100, 0
+	if raw := strings.TrimSpace(c.Query("limit")); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed <= 0 || parsed > 1000 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid limit: expected 1-1000"})
+			return
+		}
+		limit = parsed
+	}
+	if raw := strings.TrimSpace(c.Query("offset")); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid offset"})
+			return
+		}
+		offset = parsed
+	}
+
+	records, ok := h.usageRecords(c, from, to)
+	if !ok {
+		return
+	}
+	total := len(records)
+	start := min(offset, total)
+	end := min(start+limit, total)
+	response := gin.H{
+		"records": records[start:end],
+		"total":   total,
+	}
+	if end < total {
+		response["next_offset"] = end
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// GetUsageSchema describes the flattened usage record layout for external consumers.
+// An optional version query parameter limits the listing to columns available at that version.
+func (h *Handler) GetUsageSchema(c *gin.Context) {
+	version := 0
+	if raw := strings.TrimSpace(c.Query("version")); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid version"})
+			return
+		}
+		if parsed > usage.SchemaVersion {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "unknown schema version"})
+			return
+		}
+		version = parsed
+	}
+	c.JSON(http.StatusOK, usage.DescribeSchema(version))
+}
+
+// RecomputeUsageCosts re-estimates stored request costs with the current model pricing.
+// By default only requests recorded without a cost are updated; all=true recomputes every request.
+func (h *Handler) RecomputeUsageCosts(c *gin.Context) {
+	if h == nil || h.usageStats == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "usage statistics unavailable"})
+		return
+	}
+	all := false
+	if raw := strings.TrimSpace(c.Query("all")); raw != "" {
+		parsed, err := strconv.ParseBool(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid all"})
+			return
+		}
+		all = parsed
+	}
+	updated := h.usageStats.RecomputeCosts(!all)
+	snapshot := h.usageStats.Snapshot()
+	c.JSON(http.StatusOK, gin.H{
+		"updated":                 updated,
+		"total_cost_microdollars": snapshot.TotalCostMicrodollars,
 	})
 }
 
@@ -77,3 +470,202 @@ func (h *Handler) ImportUsageStatistics(c *gin.Context) {
 		"failed_requests": snapshot.FailureCount,
 	})
 }
+
+// ImportUsageCSV merges usage records from a CSV request body into memory.
+// Query parameters:
+//   - map: repeated "csv_header=column" pairs mapping CSV headers to schema columns
+//   - default: repeated "column=value" pairs supplying constants for missing columns
+//   - timestamp_layout: Go time layout, "unix" or "unix_ms" (default RFC3339)
+//   - timezone: IANA zone for timestamps without an offset (default UTC)
+//   - dry_run: when true, nothing is merged and the first preview records are returned
+//   - preview: number of mapped records returned by a dry run (default 10)
+func (h *Handler) ImportUsageCSV(c *gin.Context) {
+	if h == nil || h.usageStats == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "usage statistics unavailable"})
+		return
+	}
+
+	opts := usage.CSVImportOptions{
+		Columns:         make(map[string]string),
+		Defaults:        make(map[string]string),
+		TimestampLayout: c.Query("timestamp_layout"),
+	}
+	for _, pair := range c.QueryArray("map") {
+		header, column, ok := strings.Cut(pair, "=")
+		if !ok || strings.TrimSpace(header) == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid map " + pair})
+			return
+		}
+		opts.Columns[strings.TrimSpace(header)] = strings.TrimSpace(column)
+	}
+	for _, pair := range c.QueryArray("default") {
+		column, value, ok := strings.Cut(pair, "=")
+		if !ok || strings.TrimSpace(column) == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid default " + pair})
+			return
+		}
+		opts.Defaults[strings.TrimSpace(column)] = value
+	}
+	location, ok := usageTimezone(c, time.UTC)
+	if !ok {
+		return
+	}
+	opts.Location = location
+	dryRun := false
+	if raw := strings.TrimSpace(c.Query("dry_run")); raw != "" {
+		parsed, err := strconv.ParseBool(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid dry_run"})
+			return
+		}
+		dryRun = parsed
+	}
+	preview := 10
+	if raw := strings.TrimSpace(c.Query("preview")); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid preview"})
+			return
+		}
+		preview = parsed
+	}
+
+	records, rejected, err := usage.ParseCSVRecords(c.Request.Body, opts)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if records == nil {
+		records = []usage.FlatRecord{}
+	}
+	if rejected == nil {
+		rejected = []usage.CSVRowError{}
+	}
+
+	if dryRun {
+		if preview > len(records) {
+			preview = len(records)
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"dry_run":  true,
+			"records":  len(records),
+			"rejected": rejected,
+			"preview":  records[:preview],
+		})
+		return
+	}
+
+	result := h.usageStats.MergeSnapshot(usage.SnapshotFromRecords(records))
+	snapshot := h.usageStats.Snapshot()
+	c.JSON(http.StatusOK, gin.H{
+		"added":           result.Added,
+		"skipped":         result.Skipped,
+		"rejected":        rejected,
+		"total_requests":  snapshot.TotalRequests,
+		"failed_requests": snapshot.FailureCount,
+	})
+}
+
+// usageRecords returns the live records between from and to, merged in order with the
+// archived ones when the include_archived query parameter is true. Client API keys are redacted for
+// callers that may not see them. It writes a 400 response when the flag is invalid.
+func (h *Handler) usageRecords(c *gin.Context, from, to time.Time) ([]usage.FlatRecord, bool) {
+	includeArchived := false
+	if raw := strings.TrimSpace(c.Query("include_archived")); raw != "" {
+		parsed, err := strconv.ParseBool(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid include_archived"})
+			return nil, false
+		}
+		includeArchived = parsed
+	}
+	if h == nil || h.usageStats == nil {
+		return []usage.FlatRecord{}, true
+	}
+	records := h.usageStats.Records(from, to)
+	if includeArchived {
+		records = usage.MergeRecords(records, h.usageStats.ArchivedRecords(from, to))
+	}
+	if !canViewAPIKeys(c) {
+		records = usage.RedactRecords(records)
+	}
+	return records, true
+}
+
+// usageSnapshot returns the live statistics snapshot, with client API keys redacted for
+// callers that may not see them.
+func (h *Handler) usageSnapshot(c *gin.Context) usage.StatisticsSnapshot {
+	var snapshot usage.StatisticsSnapshot
+	if h != nil && h.usageStats != nil {
+		snapshot = h.usageStats.Snapshot()
+	}
+	if !canViewAPIKeys(c) {
+		snapshot = usage.RedactSnapshot(snapshot)
+	}
+	return snapshot
+}
+
+// ResetUsageStatistics starts a new period: every request of the api_key named by the optional
+// scope query parameter (all keys when omitted) that started up to now is moved from the live
+// statistics to the archive. The response carries a snapshot of the archived requests. When a
+// usage store is configured the reset is persisted first, so it survives a restart. Archived
+// requests stay available with include_archived=true.
+func (h *Handler) ResetUsageStatistics(c *gin.Context) {
+	if h == nil || h.usageStats == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "usage statistics unavailable"})
+		return
+	}
+	marker := usage.ResetMarker{At: time.Now(), APIKey: strings.TrimSpace(c.Query("scope"))}
+	if persistence := usage.CurrentPersistence(); persistence != nil {
+		if err := persistence.AppendReset(marker); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to persist reset: %v", err)})
+			return
+		}
+	}
+	archived := h.usageStats.Reset(marker)
+	c.JSON(http.StatusOK, gin.H{
+		"reset_at": marker.At,
+		"scope":    marker.APIKey,
+		"archived": len(archived),
+		"snapshot": usage.SnapshotFromRecords(archived),
+	})
+}
+
+// usageTimeRange parses the optional RFC3339 from/to query parameters, writing a 400
+// response when either is invalid.
+func usageTimeRange(c *gin.Context) (from, to time.Time, ok bool) {
+	for _, bound := range []struct {
+		name string
+		dst  *time.Time
+	}{{"from", &from}, {"to", &to}} {
+		raw := strings.TrimSpace(c.Query(bound.name))
+		if raw == "" {
+			continue
+		}
+		parsed, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid " + bound.name + ": expected an RFC3339 timestamp such as 2006-01-02T15:04:05Z"})
+			return time.Time{}, time.Time{}, false
+		}
+		*bound.dst = parsed
+	}
+	if !from.IsZero() && !to.IsZero() && to.Before(from) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid range: to is before from"})
+		return time.Time{}, time.Time{}, false
+	}
+	return from, to, true
+}
+
+// usageTimezone resolves the timezone query parameter, writing a 400 response when it is invalid.
+func usageTimezone(c *gin.Context, fallback *time.Location) (*time.Location, bool) {
+	tz := strings.TrimSpace(c.Query("timezone"))
+	if tz == "" {
+		return fallback, true
+	}
+	location, err := time.LoadLocation(tz)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid timezone"})
+		return nil, false
+	}
+	return location, true
+}
@@ -0,0 +1,75 @@
+package management
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
+)
+
+// GetUsageStore reports the active usage store directory and whether persistence is enabled.
+func (h *Handler) GetUsageStore(c *gin.Context) {
+	persistence := usage.CurrentPersistence()
+	if persistence == nil {
+		c.JSON(http.StatusOK, usage.PersistenceStatus{Backend: "memory"})
+		return
+	}
+	c.JSON(http.StatusOK, persistence.Status())
+}
+
+// PutUsageStore pauses or resumes usage persistence and can move the store to another
+// directory without a restart. A new directory is saved to the config so it survives a
+// restart; the enabled flag is runtime only. copy=true appends the records of the current
+// store to the new one. Only the secret key may change the directory, since it lets the
+// caller choose where the proxy writes files.
+func (h *Handler) PutUsageStore(c *gin.Context) {
+	var body struct {
+		Enabled *bool   `json:"enabled"`
+		Dir     *string `json:"dir"`
+		Copy    bool    `json:"copy"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil || (body.Enabled == nil && body.Dir == nil) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid body"})
+		return
+	}
+	if body.Dir != nil && requestManagementToken(c) != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "changing dir requires the management secret key"})
+		return
+	}
+	persistence := usage.CurrentPersistence()
+	if persistence == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "usage persistence is not configured"})
+		return
+	}
+
+	copied := 0
+	if body.Dir != nil {
+		dir := strings.TrimSpace(*body.Dir)
+		if dir == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "dir must not be empty"})
+			return
+		}
+		var err error
+		if copied, err = persistence.Switch(dir, body.Copy); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if h.cfg != nil && h.cfg.UsageStore.Dir != dir {
+			h.mu.Lock()
+			h.cfg.UsageStore.Dir = dir
+			err = config.SaveConfigPreserveComments(h.configFilePath, h.cfg)
+			h.mu.Unlock()
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("store switched but failed to save config: %v", err)})
+				return
+			}
+		}
+	}
+	if body.Enabled != nil {
+		persistence.SetEnabled(*body.Enabled)
+	}
+	c.JSON(http.StatusOK, gin.H{"status": persistence.Status(), "copied": copied})
+}
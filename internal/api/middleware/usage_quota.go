@@ -0,0 +1,50 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
+)
+
+// UsageQuotaMiddleware rejects requests from client API keys that have reached a configured
+// usage quota with 429 Too Many Requests. It must run after authentication so the API key is
+// available on the context. The configuration is read on every request so reloads apply.
+func UsageQuotaMiddleware(currentConfig func() *config.Config, stats *usage.RequestStatistics) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		cfg := currentConfig()
+		if cfg == nil || len(cfg.UsageQuotas) == 0 || stats == nil {
+			c.Next()
+			return
+		}
+		apiKey := usage.CanonicalAPIKey(c.GetString("apiKey"))
+		if apiKey == "" {
+			c.Next()
+			return
+		}
+		for _, quota := range cfg.UsageQuotas {
+			if usage.CanonicalAPIKey(quota.APIKey) != apiKey {
+				continue
+			}
+			now := time.Now()
+			violation := usage.CheckQuota(quota, stats.QuotaUsage(apiKey, now), now)
+			if violation == nil {
+				break
+			}
+			retryAfter := int64(violation.ResetsAt.Sub(now).Seconds()) + 1
+			c.Header("Retry-After", strconv.FormatInt(retryAfter, 10))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
+				"error":     "usage quota exceeded",
+				"limit":     violation.Limit,
+				"max":       violation.Max,
+				"used":      violation.Used,
+				"resets_at": violation.ResetsAt,
+			})
+			return
+		}
+		c.Next()
+	}
+}
@@ -317,7 +317,7 @@ func (s *Server) setupRoutes() {
 
 	// OpenAI compatible API routes
 	v1 := s.engine.Group("/v1")
-	v1.Use(AuthMiddleware(s.accessManager))
+	v1.Use(AuthMiddleware(s.accessManager), s.usageQuotaMiddleware())
 	{
 		v1.GET("/models", s.unifiedModelsHandler(openaiHandlers, claudeCodeHandlers))
 		v1.POST("/chat/completions", openaiHandlers.ChatCompletions)
@@ -329,7 +329,7 @@ func (s *Server) setupRoutes() {
 
 	// Gemini compatible API routes
 	v1beta := s.engine.Group("/v1beta")
-	v1beta.Use(AuthMiddleware(s.accessManager))
+	v1beta.Use(AuthMiddleware(s.accessManager), s.usageQuotaMiddleware())
 	{
 		v1beta.GET("/models", geminiHandlers.GeminiModels)
 		v1beta.POST("/models/*action", geminiHandlers.GeminiHandler)
@@ -496,8 +496,28 @@ func (s *Server) registerManagementRoutes() {
 	mgmt.Use(s.managementAvailabilityMiddleware(), s.mgmt.Middleware())
 	{
 		mgmt.GET("/usage", s.mgmt.GetUsageStatistics)
+		mgmt.GET("/usage/system", s.mgmt.GetSystemUsageStatistics)
+		mgmt.GET("/usage/trend", s.mgmt.GetUsageTrend)
+		mgmt.GET("/usage/quotas", s.mgmt.GetUsageQuotas)
+		mgmt.GET("/usage/summary", s.mgmt.GetUsageSummary)
+		mgmt.GET("/usage/top", s.mgmt.GetUsageTop)
+		mgmt.GET("/usage/series", s.mgmt.GetUsageSeries)
+		mgmt.GET("/usage/credentials/health", s.mgmt.GetUsageCredentialHealth)
+		mgmt.GET("/usage/store", s.mgmt.GetUsageStore)
+		mgmt.POST("/usage/reset", s.mgmt.ResetUsageStatistics)
+		mgmt.PUT("/usage/store", s.mgmt.PutUsageStore)
+		mgmt.GET("/usage/credentials", s.mgmt.GetUsageByAuth)
+		mgmt.GET("/usage/explore", s.mgmt.GetUsageExplore)
+		mgmt.GET("/usage/records", s.mgmt.GetUsageRecords)
 		mgmt.GET("/usage/export", s.mgmt.ExportUsageStatistics)
 		mgmt.POST("/usage/import", s.mgmt.ImportUsageStatistics)
+		mgmt.POST("/usage/import/csv", s.mgmt.ImportUsageCSV)
+		mgmt.GET("/usage/schema", s.mgmt.GetUsageSchema)
+		mgmt.POST("/usage/costs/recompute", s.mgmt.RecomputeUsageCosts)
+		mgmt.GET("/usage/key-aliases", s.mgmt.GetUsageKeyAliases)
+		mgmt.PUT("/usage/key-aliases", s.mgmt.PutUsageKeyAlias)
+		mgmt.DELETE("/usage/key-aliases", s.mgmt.DeleteUsageKeyAlias)
+		mgmt.POST("/usage/key-aliases/merge", s.mgmt.MergeUsageKeyAliases)
 		mgmt.GET("/config", s.mgmt.GetConfig)
 		mgmt.GET("/config.yaml", s.mgmt.GetConfigYAML)
 		mgmt.PUT("/config.yaml", s.mgmt.PutConfigYAML)
@@ -534,6 +554,9 @@ func (s *Server) registerManagementRoutes() {
 		mgmt.PUT("/quota-exceeded/switch-preview-model", s.mgmt.PutSwitchPreviewModel)
 		mgmt.PATCH("/quota-exceeded/switch-preview-model", s.mgmt.PutSwitchPreviewModel)
 
+		mgmt.GET("/management-tokens", s.mgmt.GetManagementTokens)
+		mgmt.DELETE("/management-tokens", s.mgmt.DeleteManagementToken)
+
 		mgmt.GET("/api-keys", s.mgmt.GetAPIKeys)
 		mgmt.PUT("/api-keys", s.mgmt.PutAPIKeys)
 		mgmt.PATCH("/api-keys", s.mgmt.PatchAPIKeys)
@@ -925,6 +948,21 @@ func (s *Server) UpdateClients(cfg *config.Config) {
 		}
 	}
 
+	if oldCfg == nil || oldCfg.UsageStatisticsHorizonDays != cfg.UsageStatisticsHorizonDays {
+		usage.SetStatisticsHorizon(time.Duration(cfg.UsageStatisticsHorizonDays) * 24 * time.Hour)
+	}
+
+	if err := usage.SetKeyAliases(cfg.UsageKeyAliases); err != nil {
+		log.Errorf("usage-key-aliases ignored: %v", err)
+	}
+	if err := usage.SetClientMetadataMode(cfg.UsageClientMetadata); err != nil {
+		log.Errorf("usage-client-metadata ignored: %v", err)
+	}
+
+	// Pricing only affects requests recorded from now on; stored costs are left untouched.
+	usage.SetPricing(cfg.ModelPricing)
+	usage.SetUsageAlerts(cfg.UsageAlerts)
+
 	if oldCfg == nil || oldCfg.DisableCooling != cfg.DisableCooling {
 		auth.SetQuotaCooldownDisabled(cfg.DisableCooling)
 		if oldCfg != nil {
@@ -934,6 +972,15 @@ func (s *Server) UpdateClients(cfg *config.Config) {
 		}
 	}
 
+	if oldCfg == nil || oldCfg.CredentialHealth != cfg.CredentialHealth {
+		usage.SetCredentialHealth(cfg.CredentialHealth)
+		if cfg.CredentialHealth.Enabled {
+			auth.SetHealthChecker(usage.CredentialUnhealthy)
+		} else {
+			auth.SetHealthChecker(nil)
+		}
+	}
+
 	if oldCfg == nil || oldCfg.CodexInstructionsEnabled != cfg.CodexInstructionsEnabled {
 		misc.SetCodexInstructionsEnabled(cfg.CodexInstructionsEnabled)
 		if oldCfg != nil {
@@ -1057,6 +1104,11 @@ func (s *Server) SetWebsocketAuthChangeHandler(fn func(bool, bool)) {
 
 // (management handlers moved to internal/api/handlers/management)
 
+// usageQuotaMiddleware enforces the configured per-key usage quotas against the shared statistics store.
+func (s *Server) usageQuotaMiddleware() gin.HandlerFunc {
+	return middleware.UsageQuotaMiddleware(func() *config.Config { return s.cfg }, usage.GetRequestStatistics())
+}
+
 // AuthMiddleware returns a Gin middleware handler that authenticates requests
 // using the configured authentication providers. When no providers are available,
 // it allows all requests (legacy behaviour).
@@ -15,6 +15,7 @@ import (
 
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -226,7 +227,13 @@ func (k *KiroAuth) ListAvailableModels(ctx context.Context, tokenData *KiroToken
 		"profileArn": tokenData.ProfileArn,
 	}
 
+	requestedAt := time.Now()
 	body, err := k.makeRequest(ctx, targetListModels, tokenData.AccessToken, payload)
+	coreusage.PublishSystemRecord(ctx, coreusage.PurposeModelRefresh, coreusage.Record{
+		Provider:    "kiro",
+		RequestedAt: requestedAt,
+		Failed:      err != nil,
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -12,6 +12,7 @@ import (
 
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/api"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
 	"github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy"
 	log "github.com/sirupsen/logrus"
 )
@@ -43,6 +44,18 @@ func StartService(cfg *config.Config, configPath string, localPassword string) {
 		}))
 	}
 
+	store, err := usage.OpenUsageStore(cfg.UsageStore, usage.GetRequestStatistics())
+	if err != nil {
+		log.Errorf("usage store disabled: %v", err)
+	} else if store != nil {
+		defer func() {
+			usage.GetRequestStatistics().SetRecordSink(nil)
+			if errClose := store.Close(); errClose != nil {
+				log.Errorf("failed to close usage store: %v", errClose)
+			}
+		}()
+	}
+
 	service, err := builder.Build()
 	if err != nil {
 		log.Errorf("failed to build proxy service: %v", err)
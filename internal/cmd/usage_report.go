@@ -0,0 +1,138 @@
+package cmd
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
+)
+
+// UsageReportOptions selects the records and layout of an offline usage report.
+type UsageReportOptions struct {
+	// Dir is the usage-store directory of the jsonl backend.
+	Dir string
+	// From and To are YYYY-MM-DD dates (To inclusive) or RFC3339 timestamps; empty is unbounded.
+	From, To string
+	// GroupBy is one of usage.SummaryGroupings.
+	GroupBy string
+	// Format is table, json or csv.
+	Format string
+	// Top limits the report to the N heaviest rows when positive.
+	Top int
+}
+
+// RunUsageReport summarises the usage persisted in opts.Dir and writes it to w. It uses the
+// same aggregation as the management summary endpoints and reads the store without locking
+// it, so it is safe to run next to a live proxy.
+func RunUsageReport(w io.Writer, opts UsageReportOptions) error {
+	from, err := parseReportTime(opts.From, false)
+	if err != nil {
+		return fmt.Errorf("invalid from: %w", err)
+	}
+	to, err := parseReportTime(opts.To, true)
+	if err != nil {
+		return fmt.Errorf("invalid to: %w", err)
+	}
+	if !from.IsZero() && !to.IsZero() && to.Before(from) {
+		return fmt.Errorf("to is before from")
+	}
+	groupBy := strings.TrimSpace(opts.GroupBy)
+	if groupBy == "" {
+		groupBy = "model"
+	}
+
+	records, err := usage.ReadFileStore(opts.Dir, from, to)
+	if err != nil {
+		return err
+	}
+	var rows []usage.SummaryRow
+	if opts.Top > 0 {
+		rows, err = usage.TopUsage(records, groupBy, opts.Top)
+	} else {
+		rows, err = usage.Summarize(records, groupBy)
+	}
+	if err != nil {
+		return err
+	}
+
+	switch strings.ToLower(strings.TrimSpace(opts.Format)) {
+	case "", "table":
+		return writeReportTable(w, groupBy, rows)
+	case "json":
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		report := map[string]any{"group_by": groupBy, "rows": rows}
+		if !from.IsZero() {
+			report["from"] = from
+		}
+		if !to.IsZero() {
+			report["to"] = to
+		}
+		return encoder.Encode(report)
+	case "csv":
+		return writeReportCSV(w, groupBy, rows)
+	default:
+		return fmt.Errorf("unsupported format %q", opts.Format)
+	}
+}
+
+// parseReportTime accepts a date or an RFC3339 timestamp. A date used as the end of a range
+// covers the whole day.
+func parseReportTime(raw string, endOfDay bool) (time.Time, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return time.Time{}, nil
+	}
+	if day, err := time.ParseInLocation("2006-01-02", raw, time.UTC); err == nil {
+		if endOfDay {
+			return day.AddDate(0, 0, 1).Add(-time.Nanosecond), nil
+		}
+		return day, nil
+	}
+	return time.Parse(time.RFC3339, raw)
+}
+
+var reportColumns = []string{"requests", "failed", "input_tokens", "output_tokens", "reasoning_tokens", "cached_tokens", "total_tokens", "cost_usd"}
+
+func reportRow(row usage.SummaryRow) []string {
+	return []string{
+		strconv.FormatInt(row.Requests, 10),
+		strconv.FormatInt(row.Failed, 10),
+		strconv.FormatInt(row.InputTokens, 10),
+		strconv.FormatInt(row.OutputTokens, 10),
+		strconv.FormatInt(row.ReasoningTokens, 10),
+		strconv.FormatInt(row.CachedTokens, 10),
+		strconv.FormatInt(row.TotalTokens, 10),
+		strconv.FormatFloat(float64(row.CostMicrodollars)/1e6, 'f', 4, 64),
+	}
+}
+
+func writeReportTable(w io.Writer, groupBy string, rows []usage.SummaryRow) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	header := append([]string{strings.ToUpper(groupBy)}, reportColumns...)
+	_, _ = fmt.Fprintln(tw, strings.ToUpper(strings.Join(header, "\t"))+"\t")
+	for _, row := range rows {
+		_, _ = fmt.Fprintln(tw, row.Key+"\t"+strings.Join(reportRow(row), "\t")+"\t")
+	}
+	return tw.Flush()
+}
+
+func writeReportCSV(w io.Writer, groupBy string, rows []usage.SummaryRow) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(append([]string{groupBy}, reportColumns...)); err != nil {
+		return err
+	}
+	for _, row := range rows {
+		if err := writer.Write(append([]string{row.Key}, reportRow(row)...)); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
@@ -0,0 +1,122 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
+)
+
+func TestRunUsageReport(t *testing.T) {
+	dir := t.TempDir()
+	store, err := usage.NewFileStore(config.UsageStore{Dir: dir})
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	for i, record := range []struct {
+		model string
+		at    time.Time
+	}{
+		{"m1", time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC)},
+		{"m1", time.Date(2025, 3, 2, 23, 30, 0, 0, time.UTC)},
+		{"m2", time.Date(2025, 3, 3, 8, 0, 0, 0, time.UTC)},
+	} {
+		if err = store.Append(usage.FlatRecord{APIKey: "k", Model: record.model, RequestDetail: usage.RequestDetail{
+			Timestamp: record.at,
+			RequestID: string(rune('a' + i)),
+			Tokens:    usage.TokenStats{InputTokens: 10, OutputTokens: 5, TotalTokens: 15},
+		}}); err != nil {
+			t.Fatalf("append: %v", err)
+		}
+	}
+	if err = store.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		opts    UsageReportOptions
+		wantErr string
+		check   func(t *testing.T, out string)
+	}{
+		{
+			name: "to date covers the whole day",
+			opts: UsageReportOptions{From: "2025-03-01", To: "2025-03-02", Format: "json"},
+			check: func(t *testing.T, out string) {
+				var report struct {
+					GroupBy string             `json:"group_by"`
+					Rows    []usage.SummaryRow `json:"rows"`
+				}
+				if err := json.Unmarshal([]byte(out), &report); err != nil {
+					t.Fatalf("decode json: %v\n%s", err, out)
+				}
+				if report.GroupBy != "model" || len(report.Rows) != 1 || report.Rows[0].Key != "m1" || report.Rows[0].Requests != 2 {
+					t.Fatalf("unexpected report: %+v", report)
+				}
+			},
+		},
+		{
+			name:    "inverted range",
+			opts:    UsageReportOptions{From: "2025-03-03", To: "2025-03-01"},
+			wantErr: "to is before from",
+		},
+		{
+			name:    "invalid date",
+			opts:    UsageReportOptions{From: "March 1st"},
+			wantErr: "invalid from",
+		},
+		{
+			name:    "unsupported format",
+			opts:    UsageReportOptions{Format: "xml"},
+			wantErr: "unsupported format",
+		},
+		{
+			name: "table",
+			opts: UsageReportOptions{},
+			check: func(t *testing.T, out string) {
+				lines := strings.Split(strings.TrimRight(out, "\n"), "\n")
+				if len(lines) != 3 || !strings.Contains(lines[0], "MODEL") || !strings.Contains(lines[0], "COST_USD") {
+					t.Fatalf("unexpected table:\n%s", out)
+				}
+				if fields := strings.Fields(lines[1]); len(fields) != 9 || fields[0] != "m1" || fields[1] != "2" {
+					t.Fatalf("unexpected first row %q", lines[1])
+				}
+			},
+		},
+		{
+			name: "csv top",
+			opts: UsageReportOptions{GroupBy: "model", Format: "csv", Top: 1},
+			check: func(t *testing.T, out string) {
+				rows, err := csv.NewReader(strings.NewReader(out)).ReadAll()
+				if err != nil {
+					t.Fatalf("read csv: %v", err)
+				}
+				if len(rows) != 2 || rows[0][0] != "model" || rows[0][len(rows[0])-1] != "cost_usd" || rows[1][0] != "m1" || rows[1][1] != "2" {
+					t.Fatalf("unexpected csv: %v", rows)
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.opts.Dir = dir
+			var out bytes.Buffer
+			err := RunUsageReport(&out, tt.opts)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("RunUsageReport: %v", err)
+			}
+			tt.check(t, out.String())
+		})
+	}
+}
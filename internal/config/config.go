@@ -12,6 +12,7 @@ import (
 	"os"
 	"strings"
 	"syscall"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 	"golang.org/x/crypto/bcrypt"
@@ -54,9 +55,39 @@ type Config struct {
 	// UsageStatisticsEnabled toggles in-memory usage aggregation; when false, usage data is discarded.
 	UsageStatisticsEnabled bool `yaml:"usage-statistics-enabled" json:"usage-statistics-enabled"`
 
+	// UsageStatisticsHorizonDays evicts in-memory usage details older than this many days. Zero keeps everything.
+	UsageStatisticsHorizonDays int `yaml:"usage-statistics-horizon-days,omitempty" json:"usage-statistics-horizon-days,omitempty"`
+
+	// UsageQuotas defines per client API key limits enforced from the recorded usage statistics.
+	UsageQuotas []UsageQuota `yaml:"usage-quotas,omitempty" json:"usage-quotas,omitempty"`
+
+	// UsageAlerts configures threshold alerts evaluated against live usage and delivered to a webhook.
+	UsageAlerts UsageAlerts `yaml:"usage-alerts,omitempty" json:"usage-alerts,omitempty"`
+
+	// ModelPricing lists per-model token prices used to estimate the cost of recorded requests.
+	ModelPricing []ModelPrice `yaml:"model-pricing,omitempty" json:"model-pricing,omitempty"`
+
+	// UsageKeyAliases links rotated client API keys to their replacements so usage reports and
+	// quotas treat both as one identity.
+	UsageKeyAliases []UsageKeyAlias `yaml:"usage-key-aliases,omitempty" json:"usage-key-aliases,omitempty"`
+
+	// UsageClientMetadata controls recording of the client IP, user agent and endpoint with each
+	// request: full (default), truncated-ip, hashed-ip or off.
+	UsageClientMetadata string `yaml:"usage-client-metadata,omitempty" json:"usage-client-metadata,omitempty"`
+
+	// UsageInstanceID names this proxy instance on recorded usage. When empty an ID is generated
+	// and stored in the auth directory. Read at startup.
+	UsageInstanceID string `yaml:"usage-instance-id,omitempty" json:"usage-instance-id,omitempty"`
+
+	// UsageStore selects where recorded usage is persisted in addition to memory.
+	UsageStore UsageStore `yaml:"usage-store,omitempty" json:"usage-store,omitempty"`
+
 	// DisableCooling disables quota cooldown scheduling when true.
 	DisableCooling bool `yaml:"disable-cooling" json:"disable-cooling"`
 
+	// CredentialHealth deprioritizes credentials whose recent failure rate for a model is too high.
+	CredentialHealth CredentialHealth `yaml:"credential-health,omitempty" json:"credential-health,omitempty"`
+
 	// RequestRetry defines the retry times when the request failed.
 	RequestRetry int `yaml:"request-retry" json:"request-retry"`
 	// MaxRetryInterval defines the maximum wait time in seconds before retrying a cooled-down credential.
@@ -125,6 +156,85 @@ type Config struct {
 	legacyMigrationPending bool `yaml:"-" json:"-"`
 }
 
+// UsageQuota limits the requests and tokens a client API key may consume.
+// Days roll over at UTC midnight and months on the first of the month (UTC). Zero disables a limit.
+type UsageQuota struct {
+	// APIKey is the client API key the quota applies to.
+	APIKey string `yaml:"api-key" json:"api-key"`
+	// MaxRequestsPerDay caps the number of requests per UTC day.
+	MaxRequestsPerDay int64 `yaml:"max-requests-per-day,omitempty" json:"max-requests-per-day,omitempty"`
+	// MaxTokensPerDay caps the total tokens per UTC day.
+	MaxTokensPerDay int64 `yaml:"max-tokens-per-day,omitempty" json:"max-tokens-per-day,omitempty"`
+	// MaxTokensPerMonth caps the total tokens per UTC calendar month.
+	MaxTokensPerMonth int64 `yaml:"max-tokens-per-month,omitempty" json:"max-tokens-per-month,omitempty"`
+	// CountFailedRequests counts failed requests toward MaxRequestsPerDay when true.
+	CountFailedRequests bool `yaml:"count-failed-requests,omitempty" json:"count-failed-requests,omitempty"`
+}
+
+// UsageAlerts holds the alert rules and the webhook notified when one trips.
+type UsageAlerts struct {
+	// WebhookURL receives a JSON POST for every tripped rule.
+	WebhookURL string `yaml:"webhook-url,omitempty" json:"webhook-url,omitempty"`
+	// Rules lists the thresholds to watch.
+	Rules []UsageAlertRule `yaml:"rules,omitempty" json:"rules,omitempty"`
+}
+
+// UsageAlertRule trips when Metric summed over the trailing Window reaches Threshold for the
+// requests matching Scope and ID. A rule fires at most once per Window.
+type UsageAlertRule struct {
+	// Scope selects what ID refers to: api_key, auth_index or model.
+	Scope string `yaml:"scope" json:"scope"`
+	ID    string `yaml:"id" json:"id"`
+	// Metric is total_tokens, requests, failed_requests or cost_microdollars.
+	Metric string `yaml:"metric" json:"metric"`
+	// Window is a Go duration such as "1h" or "24h".
+	Window    string `yaml:"window" json:"window"`
+	Threshold int64  `yaml:"threshold" json:"threshold"`
+}
+
+// UsageStore configures the usage persistence backend. It is read once at startup.
+type UsageStore struct {
+	// Backend is "memory" (default, nothing persisted) or "jsonl".
+	Backend string `yaml:"backend,omitempty" json:"backend,omitempty"`
+	// Dir holds the jsonl files.
+	Dir string `yaml:"dir,omitempty" json:"dir,omitempty"`
+	// RotateSizeMB rotates the active file once it reaches this size. Zero disables size rotation.
+	RotateSizeMB int `yaml:"rotate-size-mb,omitempty" json:"rotate-size-mb,omitempty"`
+	// RotateDaily rotates the active file at the first write of each UTC day.
+	RotateDaily bool `yaml:"rotate-daily,omitempty" json:"rotate-daily,omitempty"`
+	// Compress gzips rotated files.
+	Compress bool `yaml:"compress,omitempty" json:"compress,omitempty"`
+}
+
+// CredentialHealth configures failure-rate based credential selection. A credential is
+// unhealthy for a model once it served at least MinRequests requests for it within Window and
+// at least MaxFailureRatio of them failed. Zero values fall back to 10m, 20 and 0.5.
+type CredentialHealth struct {
+	Enabled bool `yaml:"enabled" json:"enabled"`
+	// Window is a Go duration such as "10m".
+	Window          string  `yaml:"window,omitempty" json:"window,omitempty"`
+	MinRequests     int64   `yaml:"min-requests,omitempty" json:"min-requests,omitempty"`
+	MaxFailureRatio float64 `yaml:"max-failure-ratio,omitempty" json:"max-failure-ratio,omitempty"`
+}
+
+// UsageKeyAlias maps a retired client API key to the key that replaced it.
+type UsageKeyAlias struct {
+	From string `yaml:"from" json:"from"`
+	To   string `yaml:"to" json:"to"`
+}
+
+// ModelPrice defines token prices for a model in US dollars per million tokens.
+// Model matches case-insensitively; a trailing "*" matches any model with that prefix.
+type ModelPrice struct {
+	Model string `yaml:"model" json:"model"`
+	// InputPerMillion prices prompt tokens.
+	InputPerMillion float64 `yaml:"input-per-million" json:"input-per-million"`
+	// OutputPerMillion prices completion and reasoning tokens.
+	OutputPerMillion float64 `yaml:"output-per-million" json:"output-per-million"`
+	// CachedPerMillion prices cached prompt tokens. When zero, cached tokens are billed as input.
+	CachedPerMillion float64 `yaml:"cached-per-million,omitempty" json:"cached-per-million,omitempty"`
+}
+
 // TLSConfig holds HTTPS server settings.
 type TLSConfig struct {
 	// Enable toggles HTTPS server mode.
@@ -146,6 +256,19 @@ type RemoteManagement struct {
 	// PanelGitHubRepository overrides the GitHub repository used to fetch the management panel asset.
 	// Accepts either a repository URL (https://github.com/org/repo) or an API releases endpoint.
 	PanelGitHubRepository string `yaml:"panel-github-repository"`
+	// Tokens lists additional management tokens restricted to specific scopes.
+	// They are honoured only while a secret key (or MANAGEMENT_PASSWORD) is configured.
+	Tokens []ManagementToken `yaml:"tokens,omitempty"`
+}
+
+// ManagementToken grants scoped access to the management API.
+type ManagementToken struct {
+	// Name identifies the token in logs and in the token listing.
+	Name string `yaml:"name" json:"name"`
+	// Key is the token value (plaintext or bcrypt hashed). Plaintext keys are hashed on load.
+	Key string `yaml:"key" json:"-"`
+	// Scopes lists the granted scopes (usage:read, usage:export, usage:admin, keys:admin).
+	Scopes []string `yaml:"scopes" json:"scopes"`
 }
 
 // QuotaExceeded defines the behavior when API quota limits are exceeded.
@@ -562,6 +685,12 @@ func LoadConfigOptional(configFile string, optional bool) (*Config, error) {
 		_ = SaveConfigPreserveCommentsUpdateNestedScalar(configFile, []string{"remote-management", "secret-key"}, hashed)
 	}
 
+	// Hash scoped management tokens the same way; persisted once the config is normalised.
+	tokensHashed, errTokens := cfg.hashManagementTokens()
+	if errTokens != nil {
+		return nil, errTokens
+	}
+
 	cfg.RemoteManagement.PanelGitHubRepository = strings.TrimSpace(cfg.RemoteManagement.PanelGitHubRepository)
 	if cfg.RemoteManagement.PanelGitHubRepository == "" {
 		cfg.RemoteManagement.PanelGitHubRepository = DefaultPanelGitHubRepository
@@ -592,6 +721,11 @@ func LoadConfigOptional(configFile string, optional bool) (*Config, error) {
 	// Sanitize OpenAI compatibility providers: drop entries without base-url
 	cfg.SanitizeOpenAICompatibility()
 
+	// Sanitize usage quotas: drop entries without api-key or limits
+	cfg.SanitizeUsageQuotas()
+	cfg.SanitizeUsageKeyAliases()
+	cfg.SanitizeUsageAlerts()
+
 	// Normalize OAuth provider model exclusion map.
 	cfg.OAuthExcludedModels = NormalizeOAuthExcludedModels(cfg.OAuthExcludedModels)
 
@@ -601,6 +735,12 @@ func LoadConfigOptional(configFile string, optional bool) (*Config, error) {
 	// Validate raw payload rules and drop invalid entries.
 	cfg.SanitizePayloadRules()
 
+	if tokensHashed && !cfg.legacyMigrationPending && !optional && configFile != "" {
+		if err := SaveConfigPreserveComments(configFile, &cfg); err != nil {
+			log.Warnf("failed to persist hashed management tokens: %v", err)
+		}
+	}
+
 	if cfg.legacyMigrationPending {
 		fmt.Println("Detected legacy configuration keys, attempting to persist the normalized config...")
 		if !optional && configFile != "" {
@@ -754,6 +894,135 @@ func (cfg *Config) SanitizeCodexKeys() {
 	cfg.CodexKey = out
 }
 
+// hashManagementTokens trims token names, drops entries without a name or key, keeps the
+// last entry per name and bcrypt-hashes plaintext keys. It reports whether any key was hashed.
+func (cfg *Config) hashManagementTokens() (bool, error) {
+	tokens := cfg.RemoteManagement.Tokens
+	if len(tokens) == 0 {
+		return false, nil
+	}
+	hashed := false
+	out := make([]ManagementToken, 0, len(tokens))
+	index := make(map[string]int, len(tokens))
+	for _, token := range tokens {
+		token.Name = strings.TrimSpace(token.Name)
+		token.Key = strings.TrimSpace(token.Key)
+		if token.Name == "" || token.Key == "" {
+			continue
+		}
+		if !looksLikeBcrypt(token.Key) {
+			value, err := hashSecret(token.Key)
+			if err != nil {
+				return false, fmt.Errorf("failed to hash management token %q: %w", token.Name, err)
+			}
+			token.Key = value
+			hashed = true
+		}
+		scopes := make([]string, 0, len(token.Scopes))
+		for _, scope := range token.Scopes {
+			if scope = strings.ToLower(strings.TrimSpace(scope)); scope != "" {
+				scopes = append(scopes, scope)
+			}
+		}
+		token.Scopes = scopes
+		if i, ok := index[token.Name]; ok {
+			out[i] = token
+			continue
+		}
+		index[token.Name] = len(out)
+		out = append(out, token)
+	}
+	cfg.RemoteManagement.Tokens = out
+	return hashed, nil
+}
+
+// SanitizeUsageQuotas trims API keys, clamps negative limits to zero and drops entries
+// without an API key or without any limit. Later entries for the same key win.
+func (cfg *Config) SanitizeUsageQuotas() {
+	if cfg == nil || len(cfg.UsageQuotas) == 0 {
+		return
+	}
+	out := make([]UsageQuota, 0, len(cfg.UsageQuotas))
+	index := make(map[string]int, len(cfg.UsageQuotas))
+	for _, q := range cfg.UsageQuotas {
+		q.APIKey = strings.TrimSpace(q.APIKey)
+		q.MaxRequestsPerDay = max(q.MaxRequestsPerDay, 0)
+		q.MaxTokensPerDay = max(q.MaxTokensPerDay, 0)
+		q.MaxTokensPerMonth = max(q.MaxTokensPerMonth, 0)
+		if q.APIKey == "" || (q.MaxRequestsPerDay == 0 && q.MaxTokensPerDay == 0 && q.MaxTokensPerMonth == 0) {
+			continue
+		}
+		if i, ok := index[q.APIKey]; ok {
+			out[i] = q
+			continue
+		}
+		index[q.APIKey] = len(out)
+		out = append(out, q)
+	}
+	cfg.UsageQuotas = out
+}
+
+// SanitizeUsageKeyAliases trims keys and drops empty or self-referencing aliases. When a key
+// is aliased more than once the last entry wins.
+func (cfg *Config) SanitizeUsageKeyAliases() {
+	if cfg == nil || len(cfg.UsageKeyAliases) == 0 {
+		return
+	}
+	out := make([]UsageKeyAlias, 0, len(cfg.UsageKeyAliases))
+	index := make(map[string]int, len(cfg.UsageKeyAliases))
+	for _, alias := range cfg.UsageKeyAliases {
+		alias.From = strings.TrimSpace(alias.From)
+		alias.To = strings.TrimSpace(alias.To)
+		if alias.From == "" || alias.To == "" || alias.From == alias.To {
+			continue
+		}
+		if i, ok := index[alias.From]; ok {
+			out[i] = alias
+			continue
+		}
+		index[alias.From] = len(out)
+		out = append(out, alias)
+	}
+	cfg.UsageKeyAliases = out
+}
+
+// SanitizeUsageAlerts trims alert settings and drops rules with an unknown scope or metric,
+// an invalid or non-positive window, or a non-positive threshold.
+func (cfg *Config) SanitizeUsageAlerts() {
+	if cfg == nil {
+		return
+	}
+	cfg.UsageAlerts.WebhookURL = strings.TrimSpace(cfg.UsageAlerts.WebhookURL)
+	if len(cfg.UsageAlerts.Rules) == 0 {
+		return
+	}
+	out := make([]UsageAlertRule, 0, len(cfg.UsageAlerts.Rules))
+	for _, rule := range cfg.UsageAlerts.Rules {
+		rule.Scope = strings.ToLower(strings.TrimSpace(rule.Scope))
+		rule.ID = strings.TrimSpace(rule.ID)
+		rule.Metric = strings.ToLower(strings.TrimSpace(rule.Metric))
+		rule.Window = strings.TrimSpace(rule.Window)
+		switch rule.Scope {
+		case "api_key", "auth_index", "model":
+		default:
+			continue
+		}
+		switch rule.Metric {
+		case "total_tokens", "requests", "failed_requests", "cost_microdollars":
+		default:
+			continue
+		}
+		if window, err := time.ParseDuration(rule.Window); err != nil || window <= 0 {
+			continue
+		}
+		if rule.ID == "" || rule.Threshold <= 0 {
+			continue
+		}
+		out = append(out, rule)
+	}
+	cfg.UsageAlerts.Rules = out
+}
+
 // SanitizeClaudeKeys normalizes headers for Claude credentials.
 func (cfg *Config) SanitizeClaudeKeys() {
 	if cfg == nil || len(cfg.ClaudeKey) == 0 {
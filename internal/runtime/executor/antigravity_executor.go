@@ -29,6 +29,7 @@ import (
 	sdkAuth "github.com/router-for-me/CLIProxyAPI/v6/sdk/auth"
 	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
 	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
 	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
 	log "github.com/sirupsen/logrus"
 	"github.com/tidwall/gjson"
@@ -340,6 +341,7 @@ func (e *AntigravityExecutor) executeClaudeNonStream(ctx context.Context, auth *
 		out := make(chan cliproxyexecutor.StreamChunk)
 		go func(resp *http.Response) {
 			defer close(out)
+			defer reporter.flushUpdate(ctx)
 			defer func() {
 				if errClose := resp.Body.Close(); errClose != nil {
 					log.Errorf("antigravity executor: close response body error: %v", errClose)
@@ -368,7 +370,7 @@ func (e *AntigravityExecutor) executeClaudeNonStream(ctx context.Context, auth *
 			}
 			if errScan := scanner.Err(); errScan != nil {
 				recordAPIResponseError(ctx, e.cfg, errScan)
-				reporter.publishFailure(ctx)
+				reporter.publishFailure(ctx, errScan)
 				out <- cliproxyexecutor.StreamChunk{Err: errScan}
 			} else {
 				reporter.ensurePublished(ctx)
@@ -706,6 +708,7 @@ func (e *AntigravityExecutor) ExecuteStream(ctx context.Context, auth *cliproxya
 		stream = out
 		go func(resp *http.Response) {
 			defer close(out)
+			defer reporter.flushUpdate(ctx)
 			defer func() {
 				if errClose := resp.Body.Close(); errClose != nil {
 					log.Errorf("antigravity executor: close response body error: %v", errClose)
@@ -742,7 +745,7 @@ func (e *AntigravityExecutor) ExecuteStream(ctx context.Context, auth *cliproxya
 			}
 			if errScan := scanner.Err(); errScan != nil {
 				recordAPIResponseError(ctx, e.cfg, errScan)
-				reporter.publishFailure(ctx)
+				reporter.publishFailure(ctx, errScan)
 				out <- cliproxyexecutor.StreamChunk{Err: errScan}
 			} else {
 				reporter.ensurePublished(ctx)
@@ -939,6 +942,12 @@ func FetchAntigravityModels(ctx context.Context, auth *cliproxyauth.Auth, cfg *c
 		auth = updatedAuth
 	}
 
+	requestedAt := time.Now()
+	fetched := false
+	defer func() {
+		publishSystemUsage(ctx, usage.PurposeModelRefresh, antigravityAuthType, auth, requestedAt, !fetched)
+	}()
+
 	baseURLs := antigravityBaseURLFallbackOrder(auth)
 	httpClient := newProxyAwareHTTPClient(ctx, cfg, auth, 0)
 
@@ -1027,6 +1036,7 @@ func FetchAntigravityModels(ctx context.Context, auth *cliproxyauth.Auth, cfg *c
 			}
 			models = append(models, modelInfo)
 		}
+		fetched = true
 		return models
 	}
 	return nil
@@ -1047,7 +1057,9 @@ func (e *AntigravityExecutor) ensureAccessToken(ctx context.Context, auth *clipr
 			refreshCtx = context.WithValue(refreshCtx, "cliproxy.roundtripper", rt)
 		}
 	}
+	requestedAt := time.Now()
 	updated, errRefresh := e.refreshToken(refreshCtx, auth.Clone())
+	publishSystemUsage(ctx, usage.PurposeTokenRefresh, antigravityAuthType, auth, requestedAt, errRefresh != nil)
 	if errRefresh != nil {
 		return "", nil, errRefresh
 	}
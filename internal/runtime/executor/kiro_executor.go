@@ -277,7 +277,7 @@ func (e *KiroExecutor) Execute(ctx context.Context, auth *cliproxyauth.Auth, req
 	// Check if token is expired before making request
 	if e.isTokenExpired(accessToken) {
 		log.Infof("kiro: access token expired, attempting refresh before request")
-		refreshedAuth, refreshErr := e.Refresh(ctx, auth)
+		refreshedAuth, refreshErr := e.refreshInline(ctx, auth)
 		if refreshErr != nil {
 			log.Warnf("kiro: pre-request token refresh failed: %v", refreshErr)
 		} else if refreshedAuth != nil {
@@ -441,7 +441,7 @@ func (e *KiroExecutor) executeWithRetry(ctx context.Context, auth *cliproxyauth.
 				if attempt < maxRetries {
 					log.Warnf("kiro: received 401 error, attempting token refresh and retry (attempt %d/%d)", attempt+1, maxRetries+1)
 
-					refreshedAuth, refreshErr := e.Refresh(ctx, auth)
+					refreshedAuth, refreshErr := e.refreshInline(ctx, auth)
 					if refreshErr != nil {
 						log.Errorf("kiro: token refresh failed: %v", refreshErr)
 						return resp, statusErr{code: httpResp.StatusCode, msg: string(respBody)}
@@ -504,7 +504,7 @@ func (e *KiroExecutor) executeWithRetry(ctx context.Context, auth *cliproxyauth.
 
 				if isTokenRelated && attempt < maxRetries {
 					log.Warnf("kiro: 403 appears token-related, attempting token refresh")
-					refreshedAuth, refreshErr := e.Refresh(ctx, auth)
+					refreshedAuth, refreshErr := e.refreshInline(ctx, auth)
 					if refreshErr != nil {
 						log.Errorf("kiro: token refresh failed: %v", refreshErr)
 						// Token refresh failed - return error immediately
@@ -614,7 +614,7 @@ func (e *KiroExecutor) ExecuteStream(ctx context.Context, auth *cliproxyauth.Aut
 	// Check if token is expired before making request
 	if e.isTokenExpired(accessToken) {
 		log.Infof("kiro: access token expired, attempting refresh before stream request")
-		refreshedAuth, refreshErr := e.Refresh(ctx, auth)
+		refreshedAuth, refreshErr := e.refreshInline(ctx, auth)
 		if refreshErr != nil {
 			log.Warnf("kiro: pre-request token refresh failed: %v", refreshErr)
 		} else if refreshedAuth != nil {
@@ -789,7 +789,7 @@ func (e *KiroExecutor) executeStreamWithRetry(ctx context.Context, auth *cliprox
 				if attempt < maxRetries {
 					log.Warnf("kiro: stream received 401 error, attempting token refresh and retry (attempt %d/%d)", attempt+1, maxRetries+1)
 
-					refreshedAuth, refreshErr := e.Refresh(ctx, auth)
+					refreshedAuth, refreshErr := e.refreshInline(ctx, auth)
 					if refreshErr != nil {
 						log.Errorf("kiro: token refresh failed: %v", refreshErr)
 						return nil, statusErr{code: httpResp.StatusCode, msg: string(respBody)}
@@ -852,7 +852,7 @@ func (e *KiroExecutor) executeStreamWithRetry(ctx context.Context, auth *cliprox
 
 				if isTokenRelated && attempt < maxRetries {
 					log.Warnf("kiro: 403 appears token-related, attempting token refresh")
-					refreshedAuth, refreshErr := e.Refresh(ctx, auth)
+					refreshedAuth, refreshErr := e.refreshInline(ctx, auth)
 					if refreshErr != nil {
 						log.Errorf("kiro: token refresh failed: %v", refreshErr)
 						// Token refresh failed - return error immediately
@@ -3077,6 +3077,15 @@ func (e *KiroExecutor) CountTokens(ctx context.Context, auth *cliproxyauth.Auth,
 	}, nil
 }
 
+// refreshInline refreshes the token mid-request and records the refresh as system usage,
+// since it bypasses the auth manager's refresh loop.
+func (e *KiroExecutor) refreshInline(ctx context.Context, auth *cliproxyauth.Auth) (*cliproxyauth.Auth, error) {
+	requestedAt := time.Now()
+	updated, err := e.Refresh(ctx, auth)
+	publishSystemUsage(ctx, usage.PurposeTokenRefresh, e.Identifier(), auth, requestedAt, err != nil)
+	return updated, err
+}
+
 // Refresh refreshes the Kiro OAuth token.
 // Supports both AWS Builder ID (SSO OIDC) and Google OAuth (social login).
 // Uses mutex to prevent race conditions when multiple concurrent requests try to refresh.
@@ -0,0 +1,117 @@
+package executor
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	usagestats "github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// usageFixtureDirEnv names the directory that receives sanitized usage payloads captured
+// from live traffic. Capture is disabled while the variable is unset or empty.
+const usageFixtureDirEnv = "USAGE_FIXTURE_DIR"
+
+// usageFixture is the on-disk format shared by the normalisation corpus under
+// testdata/usage and the fixture capture mode.
+type usageFixture struct {
+	Parser      string                `json:"parser"`
+	Description string                `json:"description,omitempty"`
+	Payload     string                `json:"payload"`
+	Present     bool                  `json:"present"`
+	Expected    usagestats.TokenStats `json:"expected"`
+}
+
+// usageFixtureParsers maps fixture parser names to the provider usage parsers.
+var usageFixtureParsers = map[string]func([]byte) (usage.Detail, bool){
+	"openai":                  func(data []byte) (usage.Detail, bool) { return parseOpenAIUsage(data), true },
+	"openai_stream":           parseOpenAIStreamUsage,
+	"openai_responses":        func(data []byte) (usage.Detail, bool) { return parseOpenAIResponsesUsage(data), true },
+	"openai_responses_stream": parseOpenAIResponsesStreamUsage,
+	"codex":                   parseCodexUsage,
+	"claude":                  func(data []byte) (usage.Detail, bool) { return parseClaudeUsage(data), true },
+	"claude_stream":           parseClaudeStreamUsage,
+	"gemini":                  func(data []byte) (usage.Detail, bool) { return parseGeminiUsage(data), true },
+	"gemini_stream":           parseGeminiStreamUsage,
+	"gemini_cli":              func(data []byte) (usage.Detail, bool) { return parseGeminiCLIUsage(data), true },
+	"gemini_cli_stream":       parseGeminiCLIStreamUsage,
+	"antigravity":             func(data []byte) (usage.Detail, bool) { return parseAntigravityUsage(data), true },
+	"antigravity_stream":      parseAntigravityStreamUsage,
+}
+
+// usageFixtureKeepPaths lists the JSON paths retained when sanitizing a captured payload.
+// Everything else (message content, IDs, model output) is dropped.
+var usageFixtureKeepPaths = []string{
+	"type",
+	"usage",
+	"usageMetadata",
+	"usage_metadata",
+	"message.usage",
+	"response.usage",
+	"response.usageMetadata",
+	"response.usage_metadata",
+}
+
+var usageFixtureSeq atomic.Int64
+
+// captureUsageFixture writes a sanitized copy of payload into the fixture directory when
+// capture mode is enabled. Failures are logged and never affect request handling.
+func captureUsageFixture(parser string, payload []byte, detail usage.Detail) {
+	dir := strings.TrimSpace(os.Getenv(usageFixtureDirEnv))
+	if dir == "" {
+		return
+	}
+	fixture := usageFixture{
+		Parser:   parser,
+		Payload:  string(sanitizeUsagePayload(payload)),
+		Present:  true,
+		Expected: usagestats.NormaliseDetail(detail),
+	}
+	data, err := json.MarshalIndent(fixture, "", "  ")
+	if err != nil {
+		log.Debugf("usage fixture: marshal failed: %v", err)
+		return
+	}
+	if err = os.MkdirAll(dir, 0o755); err != nil {
+		log.Debugf("usage fixture: create dir failed: %v", err)
+		return
+	}
+	name := fmt.Sprintf("%s-%d-%d.json", parser, time.Now().UnixNano(), usageFixtureSeq.Add(1))
+	if err = os.WriteFile(filepath.Join(dir, name), append(data, '\n'), 0o644); err != nil {
+		log.Debugf("usage fixture: write failed: %v", err)
+	}
+}
+
+// sanitizeUsagePayload rebuilds payload keeping only usage-related fields. SSE framing
+// ("data: " prefix) is preserved so stream parsers accept the captured line unchanged.
+func sanitizeUsagePayload(payload []byte) []byte {
+	trimmed := bytes.TrimSpace(payload)
+	prefix := ""
+	if bytes.HasPrefix(trimmed, []byte("data:")) {
+		prefix = "data: "
+		trimmed = bytes.TrimSpace(trimmed[len("data:"):])
+	}
+	if !gjson.ValidBytes(trimmed) {
+		return nil
+	}
+	out := []byte("{}")
+	for _, path := range usageFixtureKeepPaths {
+		node := gjson.GetBytes(trimmed, path)
+		if !node.Exists() {
+			continue
+		}
+		if updated, err := sjson.SetRawBytes(out, path, []byte(node.Raw)); err == nil {
+			out = updated
+		}
+	}
+	return append([]byte(prefix), out...)
+}
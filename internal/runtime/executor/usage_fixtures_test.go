@@ -0,0 +1,149 @@
+package executor
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	usagestats "github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
+)
+
+func TestUsageNormalisationCorpus(t *testing.T) {
+	paths, err := filepath.Glob(filepath.Join("testdata", "usage", "*.json"))
+	if err != nil {
+		t.Fatalf("glob fixtures: %v", err)
+	}
+	if len(paths) == 0 {
+		t.Fatal("no usage fixtures found under testdata/usage")
+	}
+	for _, path := range paths {
+		path := path
+		t.Run(strings.TrimSuffix(filepath.Base(path), ".json"), func(t *testing.T) {
+			data, errRead := os.ReadFile(path)
+			if errRead != nil {
+				t.Fatalf("read fixture: %v", errRead)
+			}
+			var fixture usageFixture
+			if errDecode := json.Unmarshal(data, &fixture); errDecode != nil {
+				t.Fatalf("decode fixture: %v", errDecode)
+			}
+			parse, ok := usageFixtureParsers[fixture.Parser]
+			if !ok {
+				t.Fatalf("unknown parser %q", fixture.Parser)
+			}
+			detail, present := parse([]byte(fixture.Payload))
+			if present != fixture.Present {
+				t.Fatalf("%s: usage present = %t, want %t", fixture.Parser, present, fixture.Present)
+			}
+			got := usagestats.NormaliseDetail(detail)
+			if diff := diffTokenStats(got, fixture.Expected); diff != "" {
+				t.Errorf("%s: normalised usage mismatch (-got +want):\n%s", fixture.Parser, diff)
+			}
+		})
+	}
+}
+
+func TestSanitizeUsagePayloadDropsContent(t *testing.T) {
+	line := []byte(`data: {"candidates":[{"content":{"parts":[{"text":"secret"}]}}],"usageMetadata":{"promptTokenCount":3,"totalTokenCount":5},"responseId":"r1"}`)
+	got := string(sanitizeUsagePayload(line))
+	want := `data: {"usageMetadata":{"promptTokenCount":3,"totalTokenCount":5}}`
+	if got != want {
+		t.Fatalf("sanitizeUsagePayload() = %s, want %s", got, want)
+	}
+	detail, ok := parseGeminiStreamUsage([]byte(got))
+	if !ok || detail.InputTokens != 3 || detail.TotalTokens != 5 {
+		t.Fatalf("sanitized payload no longer parses: ok=%t detail=%+v", ok, detail)
+	}
+}
+
+func TestCaptureUsageFixtureRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv(usageFixtureDirEnv, dir)
+
+	payload := []byte(`{"type":"message","content":[{"type":"text","text":"hidden"}],"usage":{"input_tokens":4,"output_tokens":6}}`)
+	expected := usagestats.NormaliseDetail(parseClaudeUsage(payload))
+
+	entries, err := os.ReadDir(dir)
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("expected one captured fixture, got %d (err=%v)", len(entries), err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, entries[0].Name()))
+	if err != nil {
+		t.Fatalf("read captured fixture: %v", err)
+	}
+	if strings.Contains(string(data), "hidden") {
+		t.Fatalf("captured fixture leaked content: %s", data)
+	}
+	var fixture usageFixture
+	if err = json.Unmarshal(data, &fixture); err != nil {
+		t.Fatalf("decode captured fixture: %v", err)
+	}
+	if fixture.Parser != "claude" || !fixture.Present {
+		t.Fatalf("unexpected fixture header: %+v", fixture)
+	}
+	if diff := diffTokenStats(fixture.Expected, expected); diff != "" {
+		t.Fatalf("captured expectation mismatch:\n%s", diff)
+	}
+}
+
+func diffTokenStats(got, want usagestats.TokenStats) string {
+	fields := []struct {
+		name      string
+		got, want int64
+	}{
+		{"input_tokens", got.InputTokens, want.InputTokens},
+		{"output_tokens", got.OutputTokens, want.OutputTokens},
+		{"reasoning_tokens", got.ReasoningTokens, want.ReasoningTokens},
+		{"cached_tokens", got.CachedTokens, want.CachedTokens},
+		{"total_tokens", got.TotalTokens, want.TotalTokens},
+	}
+	var b strings.Builder
+	for _, f := range fields {
+		if f.got != f.want {
+			fmt.Fprintf(&b, "  %s: -%d +%d\n", f.name, f.got, f.want)
+		}
+	}
+	return b.String()
+}
+
+func TestUsageFixtureCosts(t *testing.T) {
+	// Per million tokens: input 1, cached 0.1, output 10, so costs in microdollars read as
+	// uncached input + cached/10 + output*10.
+	usagestats.SetPricing([]config.ModelPrice{{Model: "*", InputPerMillion: 1, CachedPerMillion: 0.1, OutputPerMillion: 10}})
+	t.Cleanup(func() { usagestats.SetPricing(nil) })
+
+	cases := []struct {
+		fixture  string
+		provider string
+		want     int64
+	}{
+		// Reasoning (1024) is already part of the 1186 output tokens.
+		{"openai_stream_reasoning", "openai-compatibility", 75 + 1186*10},
+		// 4864 of the 5210 input tokens were cached; 512 reasoning tokens are within the output.
+		{"codex_response_completed", "codex", 346 + 486 + 733*10},
+		// Claude's 25 input tokens exclude the 4096 cache reads.
+		{"claude_messages", "claude", 25 + 410 + 312*10},
+		// Gemini reports 100 thinking tokens next to the 48 output tokens.
+		{"gemini_generate_content", "gemini", 12 + 148*10},
+		{"antigravity_generate_content", "antigravity", 500 + 100 + 200*10},
+	}
+	for _, tc := range cases {
+		data, err := os.ReadFile(filepath.Join("testdata", "usage", tc.fixture+".json"))
+		if err != nil {
+			t.Fatalf("read fixture: %v", err)
+		}
+		var fixture usageFixture
+		if err = json.Unmarshal(data, &fixture); err != nil {
+			t.Fatalf("decode fixture: %v", err)
+		}
+		detail, _ := usageFixtureParsers[fixture.Parser]([]byte(fixture.Payload))
+		tokens := usagestats.NormaliseDetail(detail)
+		if got := usagestats.EstimateCost(tc.provider, "model", tokens); got != tc.want {
+			t.Errorf("%s: cost = %d, want %d", tc.fixture, got, tc.want)
+		}
+	}
+}
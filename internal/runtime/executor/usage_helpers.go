@@ -3,12 +3,17 @@ package executor
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"net"
+	"net/http"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	usagestats "github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
 	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
 	"github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
 	"github.com/tidwall/gjson"
@@ -23,7 +28,15 @@ type usageReporter struct {
 	apiKey      string
 	source      string
 	requestedAt time.Time
+	requestID   string
 	once        sync.Once
+
+	// mu guards the outcome of the first publish and the counts merged into it since, which
+	// flushUpdate publishes as a single update.
+	mu        sync.Mutex
+	failed    bool
+	published usage.Detail
+	pending   bool
 }
 
 func newUsageReporter(ctx context.Context, provider, model string, auth *cliproxyauth.Auth) *usageReporter {
@@ -32,6 +45,7 @@ func newUsageReporter(ctx context.Context, provider, model string, auth *cliprox
 		provider:    provider,
 		model:       model,
 		requestedAt: time.Now(),
+		requestID:   uuid.NewString(),
 		apiKey:      apiKey,
 		source:      resolveUsageSource(auth, apiKey),
 	}
@@ -43,11 +57,15 @@ func newUsageReporter(ctx context.Context, provider, model string, auth *cliprox
 }
 
 func (r *usageReporter) publish(ctx context.Context, detail usage.Detail) {
-	r.publishWithOutcome(ctx, detail, false)
+	r.publishWithOutcome(ctx, detail, nil)
 }
 
-func (r *usageReporter) publishFailure(ctx context.Context) {
-	r.publishWithOutcome(ctx, usage.Detail{}, true)
+// publishFailure records the request as failed, classifying err for failure breakdowns.
+func (r *usageReporter) publishFailure(ctx context.Context, err error) {
+	if err == nil {
+		err = errors.New("request failed")
+	}
+	r.publishWithOutcome(ctx, usage.Detail{}, err)
 }
 
 func (r *usageReporter) trackFailure(ctx context.Context, errPtr *error) {
@@ -55,14 +73,15 @@ func (r *usageReporter) trackFailure(ctx context.Context, errPtr *error) {
 		return
 	}
 	if *errPtr != nil {
-		r.publishFailure(ctx)
+		r.publishFailure(ctx, *errPtr)
 	}
 }
 
-func (r *usageReporter) publishWithOutcome(ctx context.Context, detail usage.Detail, failed bool) {
+func (r *usageReporter) publishWithOutcome(ctx context.Context, detail usage.Detail, failure error) {
 	if r == nil {
 		return
 	}
+	failed := failure != nil
 	if detail.TotalTokens == 0 {
 		total := detail.InputTokens + detail.OutputTokens + detail.ReasoningTokens
 		if total > 0 {
@@ -72,19 +91,95 @@ func (r *usageReporter) publishWithOutcome(ctx context.Context, detail usage.Det
 	if detail.InputTokens == 0 && detail.OutputTokens == 0 && detail.ReasoningTokens == 0 && detail.CachedTokens == 0 && detail.TotalTokens == 0 && !failed {
 		return
 	}
+	first := false
 	r.once.Do(func() {
-		usage.PublishRecord(ctx, usage.Record{
-			Provider:    r.provider,
-			Model:       r.model,
-			Source:      r.source,
-			APIKey:      r.apiKey,
-			AuthID:      r.authID,
-			AuthIndex:   r.authIndex,
-			RequestedAt: r.requestedAt,
-			Failed:      failed,
-			Detail:      detail,
-		})
+		first = true
+		record := r.record(failed, detail)
+		if failed {
+			record.StatusCode, record.ErrorType = classifyUsageError(failure)
+		}
+		r.mu.Lock()
+		r.failed, r.published = failed, detail
+		r.mu.Unlock()
+		usage.PublishRecord(ctx, record)
 	})
+	if !first && !failed {
+		r.mergeUpdate(detail)
+	}
+}
+
+// mergeUpdate folds detail into the counts of a successful request that was already
+// published, such as usage delivered in the last event of a stream after an earlier partial
+// count. The merged counts are held until flushUpdate.
+func (r *usageReporter) mergeUpdate(detail usage.Detail) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.failed {
+		return
+	}
+	merged := mergeUsageDetail(r.provider, r.published, detail)
+	if merged != r.published {
+		r.published = merged
+		r.pending = true
+	}
+}
+
+// flushUpdate publishes the counts merged since the request was first published as one
+// update record. Streaming executors call it when the stream ends, so a stream that reports
+// usage on every chunk still yields at most one update. It is safe to call repeatedly.
+func (r *usageReporter) flushUpdate(ctx context.Context) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	if !r.pending {
+		r.mu.Unlock()
+		return
+	}
+	r.pending = false
+	merged := r.published
+	r.mu.Unlock()
+
+	record := r.record(false, merged)
+	record.Update = true
+	usage.PublishRecord(ctx, record)
+}
+
+// mergeUsageDetail combines two usage reports for one request; see usagestats.MergeTokenStats.
+func mergeUsageDetail(provider string, a, b usage.Detail) usage.Detail {
+	tokens := func(detail usage.Detail) usagestats.TokenStats {
+		return usagestats.TokenStats{
+			InputTokens:     detail.InputTokens,
+			OutputTokens:    detail.OutputTokens,
+			ReasoningTokens: detail.ReasoningTokens,
+			CachedTokens:    detail.CachedTokens,
+			TotalTokens:     detail.TotalTokens,
+		}
+	}
+	merged := usagestats.MergeTokenStats(provider, tokens(a), tokens(b))
+	return usage.Detail{
+		InputTokens:     merged.InputTokens,
+		OutputTokens:    merged.OutputTokens,
+		ReasoningTokens: merged.ReasoningTokens,
+		CachedTokens:    merged.CachedTokens,
+		TotalTokens:     merged.TotalTokens,
+	}
+}
+
+func (r *usageReporter) record(failed bool, detail usage.Detail) usage.Record {
+	return usage.Record{
+		Provider:    r.provider,
+		Model:       r.model,
+		Source:      r.source,
+		APIKey:      r.apiKey,
+		AuthID:      r.authID,
+		AuthIndex:   r.authIndex,
+		RequestedAt: r.requestedAt,
+		Failed:      failed,
+		Detail:      detail,
+		RequestID:   r.requestID,
+		Latency:     time.Since(r.requestedAt),
+	}
 }
 
 // ensurePublished guarantees that a usage record is emitted exactly once.
@@ -96,20 +191,52 @@ func (r *usageReporter) ensurePublished(ctx context.Context) {
 		return
 	}
 	r.once.Do(func() {
-		usage.PublishRecord(ctx, usage.Record{
-			Provider:    r.provider,
-			Model:       r.model,
-			Source:      r.source,
-			APIKey:      r.apiKey,
-			AuthID:      r.authID,
-			AuthIndex:   r.authIndex,
-			RequestedAt: r.requestedAt,
-			Failed:      false,
-			Detail:      usage.Detail{},
-		})
+		usage.PublishRecord(ctx, r.record(false, usage.Detail{}))
 	})
 }
 
+// classifyUsageError derives the upstream HTTP status (0 when unknown) and an error class
+// from a failed request's error.
+func classifyUsageError(err error) (int, string) {
+	status := 0
+	var coded interface{ StatusCode() int }
+	if errors.As(err, &coded) {
+		status = coded.StatusCode()
+	}
+	var netErr net.Error
+	switch {
+	case errors.Is(err, context.Canceled):
+		return status, usage.ErrorTypeCanceled
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return status, usage.ErrorTypeTimeout
+	case status == http.StatusTooManyRequests:
+		return status, usage.ErrorTypeRateLimit
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		return status, usage.ErrorTypeAuth
+	case status == http.StatusRequestTimeout || status == http.StatusGatewayTimeout:
+		return status, usage.ErrorTypeTimeout
+	case status >= 500:
+		return status, usage.ErrorTypeUpstream
+	case status >= 400:
+		return status, usage.ErrorTypeInvalidRequest
+	}
+	return status, usage.ErrorTypeOther
+}
+
+// publishSystemUsage records an upstream call the proxy made on its own behalf using auth.
+func publishSystemUsage(ctx context.Context, purpose, provider string, auth *cliproxyauth.Auth, requestedAt time.Time, failed bool) {
+	record := usage.Record{
+		Provider:    provider,
+		RequestedAt: requestedAt,
+		Failed:      failed,
+	}
+	if auth != nil {
+		record.AuthID = auth.ID
+		record.AuthIndex = auth.EnsureIndex()
+	}
+	usage.PublishSystemRecord(ctx, purpose, record)
+}
+
 func apiKeyFromContext(ctx context.Context) string {
 	if ctx == nil {
 		return ""
@@ -191,6 +318,7 @@ func parseCodexUsage(data []byte) (usage.Detail, bool) {
 	if reasoning := usageNode.Get("output_tokens_details.reasoning_tokens"); reasoning.Exists() {
 		detail.ReasoningTokens = reasoning.Int()
 	}
+	captureUsageFixture("codex", data, detail)
 	return detail, true
 }
 
@@ -210,6 +338,7 @@ func parseOpenAIUsage(data []byte) usage.Detail {
 	if reasoning := usageNode.Get("completion_tokens_details.reasoning_tokens"); reasoning.Exists() {
 		detail.ReasoningTokens = reasoning.Int()
 	}
+	captureUsageFixture("openai", data, detail)
 	return detail
 }
 
@@ -233,6 +362,7 @@ func parseOpenAIStreamUsage(line []byte) (usage.Detail, bool) {
 	if reasoning := usageNode.Get("completion_tokens_details.reasoning_tokens"); reasoning.Exists() {
 		detail.ReasoningTokens = reasoning.Int()
 	}
+	captureUsageFixture("openai_stream", line, detail)
 	return detail, true
 }
 
@@ -259,7 +389,9 @@ func parseOpenAIResponsesUsage(data []byte) usage.Detail {
 	if !usageNode.Exists() {
 		return usage.Detail{}
 	}
-	return parseOpenAIResponsesUsageDetail(usageNode)
+	detail := parseOpenAIResponsesUsageDetail(usageNode)
+	captureUsageFixture("openai_responses", data, detail)
+	return detail
 }
 
 func parseOpenAIResponsesStreamUsage(line []byte) (usage.Detail, bool) {
@@ -271,7 +403,9 @@ func parseOpenAIResponsesStreamUsage(line []byte) (usage.Detail, bool) {
 	if !usageNode.Exists() {
 		return usage.Detail{}, false
 	}
-	return parseOpenAIResponsesUsageDetail(usageNode), true
+	detail := parseOpenAIResponsesUsageDetail(usageNode)
+	captureUsageFixture("openai_responses_stream", line, detail)
+	return detail, true
 }
 
 func parseClaudeUsage(data []byte) usage.Detail {
@@ -289,6 +423,7 @@ func parseClaudeUsage(data []byte) usage.Detail {
 		detail.CachedTokens = usageNode.Get("cache_creation_input_tokens").Int()
 	}
 	detail.TotalTokens = detail.InputTokens + detail.OutputTokens
+	captureUsageFixture("claude", data, detail)
 	return detail
 }
 
@@ -298,6 +433,11 @@ func parseClaudeStreamUsage(line []byte) (usage.Detail, bool) {
 		return usage.Detail{}, false
 	}
 	usageNode := gjson.GetBytes(payload, "usage")
+	if !usageNode.Exists() {
+		// message_start carries the input tokens under message.usage; message_delta later
+		// reports the output.
+		usageNode = gjson.GetBytes(payload, "message.usage")
+	}
 	if !usageNode.Exists() {
 		return usage.Detail{}, false
 	}
@@ -310,6 +450,7 @@ func parseClaudeStreamUsage(line []byte) (usage.Detail, bool) {
 		detail.CachedTokens = usageNode.Get("cache_creation_input_tokens").Int()
 	}
 	detail.TotalTokens = detail.InputTokens + detail.OutputTokens
+	captureUsageFixture("claude_stream", line, detail)
 	return detail, true
 }
 
@@ -336,7 +477,9 @@ func parseGeminiCLIUsage(data []byte) usage.Detail {
 	if !node.Exists() {
 		return usage.Detail{}
 	}
-	return parseGeminiFamilyUsageDetail(node)
+	detail := parseGeminiFamilyUsageDetail(node)
+	captureUsageFixture("gemini_cli", data, detail)
+	return detail
 }
 
 func parseGeminiUsage(data []byte) usage.Detail {
@@ -348,7 +491,9 @@ func parseGeminiUsage(data []byte) usage.Detail {
 	if !node.Exists() {
 		return usage.Detail{}
 	}
-	return parseGeminiFamilyUsageDetail(node)
+	detail := parseGeminiFamilyUsageDetail(node)
+	captureUsageFixture("gemini", data, detail)
+	return detail
 }
 
 func parseGeminiStreamUsage(line []byte) (usage.Detail, bool) {
@@ -363,7 +508,9 @@ func parseGeminiStreamUsage(line []byte) (usage.Detail, bool) {
 	if !node.Exists() {
 		return usage.Detail{}, false
 	}
-	return parseGeminiFamilyUsageDetail(node), true
+	detail := parseGeminiFamilyUsageDetail(node)
+	captureUsageFixture("gemini_stream", line, detail)
+	return detail, true
 }
 
 func parseGeminiCLIStreamUsage(line []byte) (usage.Detail, bool) {
@@ -378,7 +525,9 @@ func parseGeminiCLIStreamUsage(line []byte) (usage.Detail, bool) {
 	if !node.Exists() {
 		return usage.Detail{}, false
 	}
-	return parseGeminiFamilyUsageDetail(node), true
+	detail := parseGeminiFamilyUsageDetail(node)
+	captureUsageFixture("gemini_cli_stream", line, detail)
+	return detail, true
 }
 
 func parseAntigravityUsage(data []byte) usage.Detail {
@@ -393,7 +542,9 @@ func parseAntigravityUsage(data []byte) usage.Detail {
 	if !node.Exists() {
 		return usage.Detail{}
 	}
-	return parseGeminiFamilyUsageDetail(node)
+	detail := parseGeminiFamilyUsageDetail(node)
+	captureUsageFixture("antigravity", data, detail)
+	return detail
 }
 
 func parseAntigravityStreamUsage(line []byte) (usage.Detail, bool) {
@@ -411,7 +562,9 @@ func parseAntigravityStreamUsage(line []byte) (usage.Detail, bool) {
 	if !node.Exists() {
 		return usage.Detail{}, false
 	}
-	return parseGeminiFamilyUsageDetail(node), true
+	detail := parseGeminiFamilyUsageDetail(node)
+	captureUsageFixture("antigravity_stream", line, detail)
+	return detail, true
 }
 
 var stopChunkWithoutUsage sync.Map
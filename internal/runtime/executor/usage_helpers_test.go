@@ -0,0 +1,121 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+)
+
+func TestClassifyUsageError(t *testing.T) {
+	cases := []struct {
+		err        error
+		wantStatus int
+		wantType   string
+	}{
+		{statusErr{code: http.StatusTooManyRequests}, 429, usage.ErrorTypeRateLimit},
+		{fmt.Errorf("wrapped: %w", statusErr{code: http.StatusUnauthorized}), 401, usage.ErrorTypeAuth},
+		{statusErr{code: http.StatusBadGateway}, 502, usage.ErrorTypeUpstream},
+		{statusErr{code: http.StatusBadRequest}, 400, usage.ErrorTypeInvalidRequest},
+		{fmt.Errorf("read body: %w", context.DeadlineExceeded), 0, usage.ErrorTypeTimeout},
+		{context.Canceled, 0, usage.ErrorTypeCanceled},
+		{errors.New("boom"), 0, usage.ErrorTypeOther},
+	}
+	for _, tc := range cases {
+		status, errorType := classifyUsageError(tc.err)
+		if status != tc.wantStatus || errorType != tc.wantType {
+			t.Fatalf("classifyUsageError(%v) = %d, %q; want %d, %q", tc.err, status, errorType, tc.wantStatus, tc.wantType)
+		}
+	}
+}
+
+type capturePlugin struct {
+	mu      sync.Mutex
+	records []usage.Record
+}
+
+func (p *capturePlugin) HandleUsage(_ context.Context, record usage.Record) {
+	p.mu.Lock()
+	p.records = append(p.records, record)
+	p.mu.Unlock()
+}
+
+func (p *capturePlugin) HandleUsageUpdate(ctx context.Context, record usage.Record) {
+	p.HandleUsage(ctx, record)
+}
+
+// capturedRecords waits until capture holds want records for model and returns them.
+func capturedRecords(t *testing.T, capture *capturePlugin, model string, want int) []usage.Record {
+	t.Helper()
+	var got []usage.Record
+	for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		capture.mu.Lock()
+		got = got[:0]
+		for _, record := range capture.records {
+			if record.Model == model {
+				got = append(got, record)
+			}
+		}
+		capture.mu.Unlock()
+		if len(got) >= want {
+			break
+		}
+	}
+	return got
+}
+
+func TestUsageReporterPublishesLateTokenUpdates(t *testing.T) {
+	capture := &capturePlugin{}
+	usage.RegisterPlugin(capture)
+
+	reporter := newUsageReporter(context.Background(), "claude", "reporter-update-model", nil)
+	reporter.publish(context.Background(), usage.Detail{InputTokens: 10})
+	reporter.publish(context.Background(), usage.Detail{InputTokens: 10})
+	// Counts growing chunk by chunk are held and published as one update at the end.
+	reporter.publish(context.Background(), usage.Detail{InputTokens: 10, OutputTokens: 2})
+	reporter.publish(context.Background(), usage.Detail{InputTokens: 10, OutputTokens: 4})
+	reporter.publishFailure(context.Background(), errors.New("late failure"))
+	reporter.ensurePublished(context.Background())
+	reporter.flushUpdate(context.Background())
+	reporter.flushUpdate(context.Background())
+
+	got := capturedRecords(t, capture, "reporter-update-model", 3)
+	if len(got) != 2 {
+		t.Fatalf("published %d records, want the request and one update: %+v", len(got), got)
+	}
+	if got[0].Update || !got[1].Update || got[0].RequestID == "" || got[0].RequestID != got[1].RequestID {
+		t.Fatalf("update should follow the request under the same ID: %+v", got)
+	}
+	if got[1].Detail.OutputTokens != 4 || got[1].Detail.TotalTokens != 14 {
+		t.Fatalf("update should carry the complete counts: %+v", got[1].Detail)
+	}
+}
+
+func TestUsageReporterMergesClaudeStreamUsage(t *testing.T) {
+	capture := &capturePlugin{}
+	usage.RegisterPlugin(capture)
+
+	reporter := newUsageReporter(context.Background(), "claude", "reporter-claude-stream-model", nil)
+	for _, line := range []string{
+		`data: {"type":"message_start","message":{"usage":{"input_tokens":1000,"output_tokens":1}}}`,
+		`data: {"type":"message_delta","delta":{"stop_reason":"end_turn"},"usage":{"output_tokens":87}}`,
+	} {
+		if detail, ok := parseClaudeStreamUsage([]byte(line)); ok {
+			reporter.publish(context.Background(), detail)
+		}
+	}
+	reporter.flushUpdate(context.Background())
+
+	got := capturedRecords(t, capture, "reporter-claude-stream-model", 2)
+	if len(got) != 2 || got[0].Detail.TotalTokens != 1001 {
+		t.Fatalf("published %+v, want message_start then one update", got)
+	}
+	if update := got[1].Detail; update.InputTokens != 1000 || update.OutputTokens != 87 || update.TotalTokens != 1087 {
+		t.Fatalf("update = %+v, want 1000 input, 87 output and 1087 total", update)
+	}
+}
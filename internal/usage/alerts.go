@@ -0,0 +1,324 @@
+package usage
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// alertQueueSize bounds undelivered alerts; further alerts are dropped with a warning.
+	alertQueueSize = 64
+	// alertDeliveryAttempts is the number of webhook attempts per alert.
+	alertDeliveryAttempts = 3
+	// alertUpdateTTL is how long the counts of a published request are kept to compute the
+	// delta of a late token update.
+	alertUpdateTTL = 10 * time.Minute
+)
+
+// AlertPayload is the JSON body posted to the alert webhook.
+type AlertPayload struct {
+	Scope       string    `json:"scope"`
+	ID          string    `json:"id"`
+	Metric      string    `json:"metric"`
+	Value       int64     `json:"value"`
+	Threshold   int64     `json:"threshold"`
+	Window      string    `json:"window"`
+	WindowStart time.Time `json:"window_start"`
+	FiredAt     time.Time `json:"fired_at"`
+}
+
+// alertSample is the contribution to a rule's metric of the records in one minute.
+type alertSample struct {
+	at    time.Time
+	value int64
+}
+
+// alertPublished holds the counts already applied for a request that may still receive a
+// late token update.
+type alertPublished struct {
+	tokens TokenStats
+	seen   time.Time
+}
+
+// alertDelta is what one record adds to each metric.
+type alertDelta struct {
+	requests, failed, tokens, cost int64
+}
+
+// alertState tracks the trailing window of one rule.
+type alertState struct {
+	rule      config.UsageAlertRule
+	window    time.Duration
+	samples   []alertSample
+	sum       int64
+	lastFired time.Time
+}
+
+// AlertEngine evaluates usage alert rules against live usage records and posts tripped
+// rules to a webhook. It implements coreusage.Plugin; delivery happens on a separate
+// goroutine so usage handling never waits for the webhook.
+type AlertEngine struct {
+	mu         sync.Mutex
+	webhookURL string
+	states     []*alertState
+	rules      []config.UsageAlertRule
+	published  map[string]alertPublished
+	pruned     time.Time
+
+	client     *http.Client
+	retryDelay time.Duration
+	queue      chan AlertPayload
+	startOnce  sync.Once
+}
+
+var defaultAlertEngine = NewAlertEngine(&http.Client{Timeout: 10 * time.Second})
+
+func init() {
+	coreusage.RegisterPlugin(defaultAlertEngine)
+}
+
+// NewAlertEngine creates an engine that delivers alerts with client.
+func NewAlertEngine(client *http.Client) *AlertEngine {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &AlertEngine{
+		client:     client,
+		retryDelay: 2 * time.Second,
+		queue:      make(chan AlertPayload, alertQueueSize),
+		published:  make(map[string]alertPublished),
+	}
+}
+
+// SetUsageAlerts applies alerts to the shared alert engine.
+func SetUsageAlerts(alerts config.UsageAlerts) { defaultAlertEngine.Configure(alerts) }
+
+// Configure replaces the webhook and rules. Window state is kept for rules that are
+// unchanged and reset for new or modified ones.
+func (e *AlertEngine) Configure(alerts config.UsageAlerts) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.webhookURL = alerts.WebhookURL
+	previous := make(map[config.UsageAlertRule]*alertState, len(e.states))
+	for _, state := range e.states {
+		previous[state.rule] = state
+	}
+	e.states = e.states[:0:0]
+	for _, rule := range alerts.Rules {
+		if state, ok := previous[rule]; ok {
+			e.states = append(e.states, state)
+			continue
+		}
+		window, err := time.ParseDuration(rule.Window)
+		if err != nil || window <= 0 {
+			continue
+		}
+		e.states = append(e.states, &alertState{rule: rule, window: window})
+	}
+}
+
+// HandleUsageUpdate implements coreusage.UpdateHandler; see HandleUsage.
+func (e *AlertEngine) HandleUsageUpdate(ctx context.Context, record coreusage.Record) {
+	e.HandleUsage(ctx, record)
+}
+
+// HandleUsage implements coreusage.Plugin. A late token update adds the tokens and cost it
+// carries beyond the counts already applied for its request, without counting the request
+// again.
+func (e *AlertEngine) HandleUsage(ctx context.Context, record coreusage.Record) {
+	if record.APIKey == coreusage.SystemAPIKey {
+		return
+	}
+	now := record.RequestedAt
+	if now.IsZero() || record.Update {
+		now = time.Now()
+	}
+	tokens := normaliseDetail(record.Detail)
+
+	var fired []AlertPayload
+	e.mu.Lock()
+	if e.webhookURL == "" || len(e.states) == 0 {
+		e.mu.Unlock()
+		return
+	}
+	delta, ok := e.recordDelta(record, tokens)
+	if !ok {
+		e.mu.Unlock()
+		return
+	}
+	for _, state := range e.states {
+		if !alertRuleMatches(state.rule, record) {
+			continue
+		}
+		var value int64
+		switch state.rule.Metric {
+		case "total_tokens":
+			value = delta.tokens
+		case "requests":
+			value = delta.requests
+		case "failed_requests":
+			value = delta.failed
+		case "cost_microdollars":
+			value = delta.cost
+		}
+		windowStart := now.Add(-state.window)
+		// Samples are bucketed per minute so long windows stay small.
+		bucket := now.Truncate(time.Minute)
+		if n := len(state.samples); n > 0 && !state.samples[n-1].at.Before(bucket) {
+			state.samples[n-1].value += value
+		} else {
+			state.samples = append(state.samples, alertSample{at: bucket, value: value})
+		}
+		state.sum += value
+		kept := 0
+		for _, sample := range state.samples {
+			if sample.at.After(windowStart) {
+				state.samples[kept] = sample
+				kept++
+				continue
+			}
+			state.sum -= sample.value
+		}
+		state.samples = state.samples[:kept]
+
+		if state.sum < state.rule.Threshold {
+			continue
+		}
+		// Fire at most once per window.
+		if !state.lastFired.IsZero() && now.Sub(state.lastFired) < state.window {
+			continue
+		}
+		state.lastFired = now
+		fired = append(fired, AlertPayload{
+			Scope:       state.rule.Scope,
+			ID:          state.rule.ID,
+			Metric:      state.rule.Metric,
+			Value:       state.sum,
+			Threshold:   state.rule.Threshold,
+			Window:      state.rule.Window,
+			WindowStart: windowStart,
+			FiredAt:     now,
+		})
+	}
+	webhookURL := e.webhookURL
+	e.mu.Unlock()
+
+	if len(fired) == 0 {
+		return
+	}
+	e.startOnce.Do(func() { go e.deliverLoop() })
+	for _, payload := range fired {
+		log.Infof("usage alert: %s %s %s reached %d (threshold %d over %s)", payload.Scope, payload.ID, payload.Metric, payload.Value, payload.Threshold, payload.Window)
+		select {
+		case e.queue <- payload:
+		default:
+			log.Warnf("usage alert: delivery queue full, dropping alert for %s %s to %s", payload.Scope, payload.ID, webhookURL)
+		}
+	}
+}
+
+// recordDelta returns what record adds to each metric. For a late update it is the growth
+// over the counts kept for the request; updates for unknown requests report false. Callers
+// hold e.mu.
+func (e *AlertEngine) recordDelta(record coreusage.Record, tokens TokenStats) (alertDelta, bool) {
+	wallNow := time.Now()
+	if wallNow.Sub(e.pruned) >= time.Minute {
+		for requestID, published := range e.published {
+			if wallNow.Sub(published.seen) > alertUpdateTTL {
+				delete(e.published, requestID)
+			}
+		}
+		e.pruned = wallNow
+	}
+
+	if !record.Update {
+		delta := alertDelta{requests: 1, tokens: tokens.TotalTokens, cost: EstimateCost(record.Provider, record.Model, tokens)}
+		if record.Failed {
+			delta.failed = 1
+		}
+		if record.RequestID != "" {
+			e.published[record.RequestID] = alertPublished{tokens: tokens, seen: wallNow}
+		}
+		return delta, true
+	}
+	previous, ok := e.published[record.RequestID]
+	if !ok {
+		return alertDelta{}, false
+	}
+	merged := MergeTokenStats(record.Provider, previous.tokens, tokens)
+	e.published[record.RequestID] = alertPublished{tokens: merged, seen: wallNow}
+	return alertDelta{
+		tokens: merged.TotalTokens - previous.tokens.TotalTokens,
+		cost:   EstimateCost(record.Provider, record.Model, merged) - EstimateCost(record.Provider, record.Model, previous.tokens),
+	}, true
+}
+
+func alertRuleMatches(rule config.UsageAlertRule, record coreusage.Record) bool {
+	switch rule.Scope {
+	case "api_key":
+		return CanonicalAPIKey(record.APIKey) == CanonicalAPIKey(rule.ID)
+	case "auth_index":
+		return record.AuthIndex == rule.ID
+	case "model":
+		return record.Model == rule.ID
+	}
+	return false
+}
+
+func (e *AlertEngine) deliverLoop() {
+	for payload := range e.queue {
+		e.mu.Lock()
+		webhookURL := e.webhookURL
+		e.mu.Unlock()
+		if webhookURL == "" {
+			continue
+		}
+		var err error
+		for attempt := 1; attempt <= alertDeliveryAttempts; attempt++ {
+			if err = e.post(webhookURL, payload); err == nil {
+				break
+			}
+			log.Warnf("usage alert: webhook attempt %d/%d failed: %v", attempt, alertDeliveryAttempts, err)
+			if attempt < alertDeliveryAttempts {
+				time.Sleep(e.retryDelay * time.Duration(attempt))
+			}
+		}
+		if err != nil {
+			log.Errorf("usage alert: giving up on alert for %s %s: %v", payload.Scope, payload.ID, err)
+		}
+	}
+}
+
+func (e *AlertEngine) post(webhookURL string, payload AlertPayload) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, webhookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if errClose := resp.Body.Close(); errClose != nil {
+			log.Errorf("usage alert: close response body: %v", errClose)
+		}
+	}()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
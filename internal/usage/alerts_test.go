@@ -0,0 +1,123 @@
+package usage
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+)
+
+func TestAlertEngineFiresOncePerWindowAndRetries(t *testing.T) {
+	var attempts atomic.Int64
+	received := make(chan AlertPayload, 4)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if attempts.Add(1) == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		var payload AlertPayload
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			t.Errorf("decode payload: %v", err)
+		}
+		received <- payload
+	}))
+	defer server.Close()
+
+	engine := NewAlertEngine(server.Client())
+	engine.retryDelay = time.Millisecond
+	engine.Configure(config.UsageAlerts{
+		WebhookURL: server.URL,
+		Rules:      []config.UsageAlertRule{{Scope: "api_key", ID: "team-a", Metric: "total_tokens", Window: "1h", Threshold: 100}},
+	})
+
+	start := time.Date(2025, 7, 1, 9, 0, 0, 0, time.UTC)
+	send := func(apiKey string, at time.Time, tokens int64) {
+		engine.HandleUsage(context.Background(), coreusage.Record{
+			APIKey:      apiKey,
+			Model:       "m",
+			RequestedAt: at,
+			Detail:      coreusage.Detail{InputTokens: tokens},
+		})
+	}
+	send("team-a", start, 60)
+	send("team-b", start, 500)
+	send("team-a", start.Add(10*time.Minute), 50)
+	send("team-a", start.Add(20*time.Minute), 50)
+
+	select {
+	case payload := <-received:
+		if payload.Scope != "api_key" || payload.ID != "team-a" || payload.Metric != "total_tokens" ||
+			payload.Value != 110 || payload.Threshold != 100 || payload.Window != "1h" {
+			t.Fatalf("unexpected payload: %+v", payload)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("alert was not delivered")
+	}
+	if attempts.Load() != 2 {
+		t.Fatalf("webhook attempts = %d, want 2 (one retry)", attempts.Load())
+	}
+
+	// Past the first window the rule may fire again.
+	send("team-a", start.Add(75*time.Minute), 100)
+	select {
+	case payload := <-received:
+		if payload.Value != 150 {
+			t.Fatalf("second alert value = %d, want 150", payload.Value)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("second alert was not delivered")
+	}
+	select {
+	case payload := <-received:
+		t.Fatalf("unexpected extra alert: %+v", payload)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestAlertEngineAppliesLateTokenUpdates(t *testing.T) {
+	received := make(chan AlertPayload, 4)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload AlertPayload
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			t.Errorf("decode payload: %v", err)
+		}
+		received <- payload
+	}))
+	defer server.Close()
+
+	engine := NewAlertEngine(server.Client())
+	engine.Configure(config.UsageAlerts{
+		WebhookURL: server.URL,
+		Rules: []config.UsageAlertRule{
+			{Scope: "model", ID: "m", Metric: "total_tokens", Window: "1h", Threshold: 150},
+			{Scope: "model", ID: "m", Metric: "requests", Window: "1h", Threshold: 2},
+		},
+	})
+	record := coreusage.Record{APIKey: "k", Model: "m", RequestedAt: time.Now(), RequestID: "r1", Detail: coreusage.Detail{InputTokens: 10, OutputTokens: 1}}
+	engine.HandleUsage(context.Background(), record)
+	// The final event of the stream carries the complete counts.
+	record.Update = true
+	record.Detail.OutputTokens = 200
+	engine.HandleUsage(context.Background(), record)
+	engine.HandleUsage(context.Background(), record)
+
+	select {
+	case payload := <-received:
+		if payload.Metric != "total_tokens" || payload.Value != 210 {
+			t.Fatalf("unexpected payload: %+v", payload)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("token alert was not delivered")
+	}
+	select {
+	case payload := <-received:
+		t.Fatalf("updates must not count as requests: %+v", payload)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
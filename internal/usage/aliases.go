@@ -0,0 +1,181 @@
+package usage
+
+import (
+	"fmt"
+	"sort"
+	"sync/atomic"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	log "github.com/sirupsen/logrus"
+)
+
+var currentKeyAliases atomic.Pointer[map[string]string]
+
+// ResolveKeyAliases flattens alias chains so every aliased key maps directly to the key at
+// the end of its chain (A→B→C resolves A and B to C). Cycles are rejected.
+func ResolveKeyAliases(aliases []config.UsageKeyAlias) (map[string]string, error) {
+	next := make(map[string]string, len(aliases))
+	for _, alias := range aliases {
+		if alias.From == "" || alias.To == "" {
+			continue
+		}
+		next[alias.From] = alias.To
+	}
+	resolved := make(map[string]string, len(next))
+	for from := range next {
+		target := from
+		visited := map[string]struct{}{from: {}}
+		for {
+			to, ok := next[target]
+			if !ok {
+				break
+			}
+			if _, seen := visited[to]; seen {
+				return nil, fmt.Errorf("usage key alias cycle involving %q", from)
+			}
+			visited[to] = struct{}{}
+			target = to
+		}
+		resolved[from] = target
+	}
+	return resolved, nil
+}
+
+// SetKeyAliases replaces the key aliases applied to usage reports and quotas. The previous
+// aliases stay in effect when aliases contain a cycle.
+func SetKeyAliases(aliases []config.UsageKeyAlias) error {
+	resolved, err := ResolveKeyAliases(aliases)
+	if err != nil {
+		return err
+	}
+	currentKeyAliases.Store(&resolved)
+	return nil
+}
+
+// CanonicalAPIKey returns the key apiKey is aliased to, or apiKey itself.
+func CanonicalAPIKey(apiKey string) string {
+	if aliases := currentKeyAliases.Load(); aliases != nil {
+		if target, ok := (*aliases)[apiKey]; ok {
+			return target
+		}
+	}
+	return apiKey
+}
+
+// MergeAliasedKeys moves the statistics recorded under aliased keys to the keys they alias,
+// so the merge survives later alias changes. It returns the number of keys merged. With a
+// usage store attached the moved requests are appended to it under their new key, which
+// supersedes the original lines on load; requests recorded without a request ID cannot be
+// superseded and return under their old key after a restart.
+func (s *RequestStatistics) MergeAliasedKeys() int {
+	if s == nil {
+		return 0
+	}
+	s.mu.Lock()
+	sink := s.sink
+	var moved []FlatRecord
+
+	merged := 0
+	for apiName, stats := range s.apis {
+		target := CanonicalAPIKey(apiName)
+		if target == apiName {
+			continue
+		}
+		if sink != nil {
+			for modelName, modelStatsValue := range stats.Models {
+				for _, detail := range modelStatsValue.Details {
+					if detail.RequestID != "" {
+						moved = append(moved, FlatRecord{APIKey: target, Model: modelName, RequestDetail: detail})
+					}
+				}
+			}
+		}
+		dst, ok := s.apis[target]
+		if !ok {
+			dst = &apiStats{Models: make(map[string]*modelStats)}
+			s.apis[target] = dst
+		}
+		mergeAPIStats(dst, stats)
+		delete(s.apis, apiName)
+		if counters, ok := s.quotas[apiName]; ok {
+			if existing, ok := s.quotas[target]; ok {
+				mergeQuotaCounters(existing, counters)
+			} else {
+				s.quotas[target] = counters
+			}
+			delete(s.quotas, apiName)
+		}
+		merged++
+	}
+	s.mu.Unlock()
+
+	for _, record := range moved {
+		if err := sink.Append(record); err != nil {
+			log.Warnf("usage: failed to persist merged request: %v", err)
+			break
+		}
+	}
+	return merged
+}
+
+func mergeAPIStats(dst, src *apiStats) {
+	dst.TotalRequests += src.TotalRequests
+	dst.TotalTokens += src.TotalTokens
+	dst.TotalCost += src.TotalCost
+	for modelName, srcModel := range src.Models {
+		dstModel, ok := dst.Models[modelName]
+		if !ok {
+			dst.Models[modelName] = srcModel
+			continue
+		}
+		dstModel.TotalRequests += srcModel.TotalRequests
+		dstModel.TotalTokens += srcModel.TotalTokens
+		dstModel.TotalCost += srcModel.TotalCost
+		dstModel.Details = append(dstModel.Details, srcModel.Details...)
+		sort.SliceStable(dstModel.Details, func(i, j int) bool {
+			return dstModel.Details[i].Timestamp.Before(dstModel.Details[j].Timestamp)
+		})
+	}
+}
+
+// mergeAPISnapshot folds src into dst for reports that present aliased keys as one.
+func mergeAPISnapshot(dst, src APISnapshot) APISnapshot {
+	dst.TotalRequests += src.TotalRequests
+	dst.TotalTokens += src.TotalTokens
+	dst.TotalCostMicrodollars += src.TotalCostMicrodollars
+	if dst.Models == nil {
+		dst.Models = make(map[string]ModelSnapshot, len(src.Models))
+	}
+	for modelName, srcModel := range src.Models {
+		dstModel, ok := dst.Models[modelName]
+		if !ok {
+			dst.Models[modelName] = srcModel
+			continue
+		}
+		dstModel.TotalRequests += srcModel.TotalRequests
+		dstModel.TotalTokens += srcModel.TotalTokens
+		dstModel.TotalCostMicrodollars += srcModel.TotalCostMicrodollars
+		details := make([]RequestDetail, 0, len(dstModel.Details)+len(srcModel.Details))
+		details = append(append(details, dstModel.Details...), srcModel.Details...)
+		sort.SliceStable(details, func(i, j int) bool { return details[i].Timestamp.Before(details[j].Timestamp) })
+		dstModel.Details = details
+		dst.Models[modelName] = dstModel
+	}
+	return dst
+}
+
+// mergeQuotaCounters adds src to dst, keeping only the most recent day and month.
+func mergeQuotaCounters(dst, src *quotaCounters) {
+	if src.month > dst.month {
+		dst.month, dst.monthTokens = src.month, src.monthTokens
+	} else if src.month == dst.month {
+		dst.monthTokens += src.monthTokens
+	}
+	if src.day > dst.day {
+		dst.day, dst.requests, dst.failed, dst.tokens = src.day, src.requests, src.failed, src.tokens
+	} else if src.day == dst.day {
+		dst.requests += src.requests
+		dst.failed += src.failed
+		dst.tokens += src.tokens
+	}
+}
@@ -0,0 +1,115 @@
+package usage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+)
+
+func TestResolveKeyAliasesFollowsChainsAndRejectsCycles(t *testing.T) {
+	resolved, err := ResolveKeyAliases([]config.UsageKeyAlias{{From: "a", To: "b"}, {From: "b", To: "c"}})
+	if err != nil {
+		t.Fatalf("resolve: %v", err)
+	}
+	if resolved["a"] != "c" || resolved["b"] != "c" {
+		t.Fatalf("unexpected resolution: %v", resolved)
+	}
+	if _, err = ResolveKeyAliases([]config.UsageKeyAlias{{From: "a", To: "b"}, {From: "b", To: "c"}, {From: "c", To: "a"}}); err == nil {
+		t.Fatal("expected a cycle error")
+	}
+}
+
+func TestKeyAliasesCombineReportsAndQuotas(t *testing.T) {
+	if err := SetKeyAliases([]config.UsageKeyAlias{{From: "old", To: "mid"}, {From: "mid", To: "new"}}); err != nil {
+		t.Fatalf("set aliases: %v", err)
+	}
+	t.Cleanup(func() { _ = SetKeyAliases(nil) })
+
+	now := time.Date(2025, 6, 10, 12, 0, 0, 0, time.UTC)
+	stats := NewRequestStatistics()
+	for i, key := range []string{"old", "mid", "new"} {
+		stats.Record(context.Background(), coreusage.Record{
+			APIKey:      key,
+			Model:       "m",
+			RequestedAt: now.Add(time.Duration(i) * time.Minute),
+			Detail:      coreusage.Detail{InputTokens: 10},
+		})
+	}
+
+	snapshot := stats.Snapshot()
+	if len(snapshot.APIs) != 1 {
+		t.Fatalf("aliased keys should be reported as one: %v", snapshot.APIs)
+	}
+	if api := snapshot.APIs["new"]; api.TotalRequests != 3 || api.TotalTokens != 30 || len(api.Models["m"].Details) != 3 {
+		t.Fatalf("unexpected combined stats: %+v", api)
+	}
+	for _, record := range stats.Records(time.Time{}, time.Time{}) {
+		if record.APIKey != "new" {
+			t.Fatalf("record reported under %q, want new", record.APIKey)
+		}
+	}
+	if used := stats.QuotaUsage("old", now); used.RequestsToday != 3 || used.TokensThisMonth != 30 {
+		t.Fatalf("quota usage should include every aliased key: %+v", used)
+	}
+
+	if merged := stats.MergeAliasedKeys(); merged != 2 {
+		t.Fatalf("merged %d keys, want 2", merged)
+	}
+	_ = SetKeyAliases(nil)
+	snapshot = stats.Snapshot()
+	if len(snapshot.APIs) != 1 || snapshot.APIs["new"].TotalRequests != 3 {
+		t.Fatalf("merged history should survive alias removal: %+v", snapshot.APIs)
+	}
+	if used := stats.QuotaUsage("new", now); used.RequestsToday != 3 {
+		t.Fatalf("quota counters should be merged: %+v", used)
+	}
+}
+
+func TestMergeAliasedKeysDedupesAndPersists(t *testing.T) {
+	if err := SetKeyAliases([]config.UsageKeyAlias{{From: "old", To: "new"}}); err != nil {
+		t.Fatalf("set aliases: %v", err)
+	}
+	t.Cleanup(func() { _ = SetKeyAliases(nil) })
+
+	dir := t.TempDir()
+	now := time.Now().Truncate(time.Second)
+	stats := NewRequestStatistics()
+	persistence, err := OpenUsageStore(config.UsageStore{Backend: "jsonl", Dir: dir}, stats)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	for i, key := range []string{"old", "new"} {
+		stats.Record(context.Background(), coreusage.Record{APIKey: key, Model: "m", RequestedAt: now.Add(time.Duration(i) * time.Minute)})
+	}
+	// An export taken before the alias was added lists the first request under the old key.
+	records := stats.Records(time.Time{}, time.Time{})
+	records[0].APIKey = "old"
+	before := SnapshotFromRecords(records)
+
+	if merged := stats.MergeAliasedKeys(); merged != 1 {
+		t.Fatalf("merged %d keys, want 1", merged)
+	}
+	// Details exported under the old key match the merged ones.
+	if result := stats.MergeSnapshot(before); result.Added != 0 || result.Skipped != 2 {
+		t.Fatalf("re-import after merge: %+v, want both skipped", result)
+	}
+	if err = persistence.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	// The merge outlives both a restart and the removal of the alias.
+	_ = SetKeyAliases(nil)
+	reloaded := NewRequestStatistics()
+	reopened, err := OpenUsageStore(config.UsageStore{Backend: "jsonl", Dir: dir}, reloaded)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer func() { _ = reopened.Close() }()
+	snapshot := reloaded.Snapshot()
+	if len(snapshot.APIs) != 1 || snapshot.APIs["new"].TotalRequests != 2 {
+		t.Fatalf("reloaded usage should stay merged under new: %+v", snapshot.APIs)
+	}
+}
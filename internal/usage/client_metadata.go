@@ -0,0 +1,83 @@
+package usage
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"sync/atomic"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Client metadata collection modes accepted by SetClientMetadataMode.
+const (
+	ClientMetadataFull        = "full"
+	ClientMetadataTruncatedIP = "truncated-ip"
+	ClientMetadataHashedIP    = "hashed-ip"
+	ClientMetadataOff         = "off"
+)
+
+var clientMetadataMode atomic.Value
+
+func init() {
+	clientMetadataMode.Store(ClientMetadataFull)
+}
+
+// SetClientMetadataMode controls whether the client IP, user agent and endpoint are recorded
+// with each request. An empty mode means full; an unknown mode is rejected and the current
+// mode is kept.
+func SetClientMetadataMode(mode string) error {
+	mode = strings.ToLower(strings.TrimSpace(mode))
+	switch mode {
+	case "":
+		mode = ClientMetadataFull
+	case ClientMetadataFull, ClientMetadataTruncatedIP, ClientMetadataHashedIP, ClientMetadataOff:
+	default:
+		return fmt.Errorf("unknown client metadata mode %q", mode)
+	}
+	clientMetadataMode.Store(mode)
+	return nil
+}
+
+// applyClientMetadata fills the client fields of detail from the gin context carried by ctx.
+// Records without an HTTP request keep them empty.
+func applyClientMetadata(ctx context.Context, detail *RequestDetail) {
+	mode, _ := clientMetadataMode.Load().(string)
+	if ctx == nil || mode == ClientMetadataOff {
+		return
+	}
+	ginCtx, ok := ctx.Value("gin").(*gin.Context)
+	if !ok || ginCtx == nil || ginCtx.Request == nil {
+		return
+	}
+	detail.UserAgent = ginCtx.Request.UserAgent()
+	if path := ginCtx.FullPath(); path != "" {
+		detail.Endpoint = ginCtx.Request.Method + " " + path
+	} else if ginCtx.Request.URL != nil {
+		detail.Endpoint = ginCtx.Request.Method + " " + ginCtx.Request.URL.Path
+	}
+	ip := ginCtx.ClientIP()
+	switch mode {
+	case ClientMetadataTruncatedIP:
+		ip = truncateIP(ip)
+	case ClientMetadataHashedIP:
+		// Keyed with the install secret: the IPv4 space is small enough to reverse a plain hash.
+		if ip != "" {
+			ip = redactedKeyPrefix + keyedDigest(ip, 8)
+		}
+	}
+	detail.ClientIP = ip
+}
+
+// truncateIP keeps the /24 network of an IPv4 address and the /48 network of an IPv6 address.
+func truncateIP(raw string) string {
+	ip := net.ParseIP(raw)
+	if ip == nil {
+		return ""
+	}
+	if v4 := ip.To4(); v4 != nil {
+		return v4.Mask(net.CIDRMask(24, 32)).String()
+	}
+	return ip.Mask(net.CIDRMask(48, 128)).String()
+}
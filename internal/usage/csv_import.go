@@ -0,0 +1,264 @@
+package usage
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CSVImportOptions configures how CSV rows are mapped onto usage records.
+type CSVImportOptions struct {
+	// Columns maps CSV header names to schema column names (see DescribeSchema).
+	// Headers that already match a column name are mapped without an entry.
+	Columns map[string]string
+	// Defaults supplies constant values for columns that are missing or empty in the CSV.
+	Defaults map[string]string
+	// TimestampLayout is the Go time layout of the timestamp column; "unix" and "unix_ms"
+	// accept epoch seconds and milliseconds. Defaults to RFC3339.
+	TimestampLayout string
+	// Location is applied to timestamps without a zone offset. Defaults to UTC.
+	Location *time.Location
+}
+
+// FlatRecord is a single usage record in the flattened schema layout.
+type FlatRecord struct {
+	APIKey string `json:"api_key"`
+	Model  string `json:"model"`
+	RequestDetail
+}
+
+// CSVRowError describes a rejected CSV row. Row numbers are 1-based and include the header.
+type CSVRowError struct {
+	Row   int    `json:"row"`
+	Error string `json:"error"`
+}
+
+var requiredCSVColumns = []string{"api_key", "model", "timestamp"}
+
+// ParseCSVRecords reads usage records from r according to opts. Rows that fail validation
+// are reported and skipped; an error is returned only when the header cannot be mapped or
+// the input cannot be read. Rows without a cost are priced with the current model prices.
+func ParseCSVRecords(r io.Reader, opts CSVImportOptions) ([]FlatRecord, []CSVRowError, error) {
+	known := make(map[string]struct{}, len(recordColumns))
+	for _, column := range recordColumns {
+		known[column.Name] = struct{}{}
+	}
+	for name := range opts.Defaults {
+		if _, ok := known[name]; !ok {
+			return nil, nil, fmt.Errorf("default for unknown column %q", name)
+		}
+	}
+
+	// Record exports start with a schema version comment; see WriteRecordsCSV.
+	buffered := bufio.NewReader(r)
+	if first, errPeek := buffered.Peek(len(csvSchemaVersionPrefix)); errPeek == nil && string(first) == csvSchemaVersionPrefix {
+		if _, errLine := buffered.ReadString('\n'); errLine != nil && !errors.Is(errLine, io.EOF) {
+			return nil, nil, fmt.Errorf("read csv schema version: %w", errLine)
+		}
+	}
+	reader := csv.NewReader(buffered)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, nil, fmt.Errorf("csv input is empty")
+		}
+		return nil, nil, fmt.Errorf("read csv header: %w", err)
+	}
+
+	targets := make([]string, len(header))
+	mapped := make(map[string]struct{}, len(header))
+	for i, name := range header {
+		name = strings.TrimSpace(strings.TrimPrefix(name, "\ufeff"))
+		target, ok := opts.Columns[name]
+		if !ok {
+			if _, isColumn := known[name]; !isColumn {
+				continue
+			}
+			target = name
+		}
+		if _, isColumn := known[target]; !isColumn {
+			return nil, nil, fmt.Errorf("csv header %q maps to unknown column %q", name, target)
+		}
+		if _, dup := mapped[target]; dup {
+			return nil, nil, fmt.Errorf("column %q is mapped more than once", target)
+		}
+		mapped[target] = struct{}{}
+		targets[i] = target
+	}
+	for _, name := range requiredCSVColumns {
+		_, inHeader := mapped[name]
+		_, hasDefault := opts.Defaults[name]
+		if !inHeader && !hasDefault {
+			return nil, nil, fmt.Errorf("required column %q is neither mapped nor defaulted", name)
+		}
+	}
+
+	var records []FlatRecord
+	var rejected []CSVRowError
+	for row := 2; ; row++ {
+		fields, errRead := reader.Read()
+		if errors.Is(errRead, io.EOF) {
+			break
+		}
+		if errRead != nil {
+			var parseErr *csv.ParseError
+			if errors.As(errRead, &parseErr) {
+				rejected = append(rejected, CSVRowError{Row: row, Error: parseErr.Err.Error()})
+				continue
+			}
+			return nil, nil, fmt.Errorf("read csv row %d: %w", row, errRead)
+		}
+		values := make(map[string]string, len(opts.Defaults)+len(fields))
+		for name, value := range opts.Defaults {
+			values[name] = value
+		}
+		for i, value := range fields {
+			if i >= len(targets) || targets[i] == "" {
+				continue
+			}
+			if value = strings.TrimSpace(value); value != "" {
+				values[targets[i]] = value
+			}
+		}
+		record, errRecord := buildFlatRecord(values, opts)
+		if errRecord != nil {
+			rejected = append(rejected, CSVRowError{Row: row, Error: errRecord.Error()})
+			continue
+		}
+		records = append(records, record)
+	}
+	return records, rejected, nil
+}
+
+func buildFlatRecord(values map[string]string, opts CSVImportOptions) (FlatRecord, error) {
+	record := FlatRecord{
+		APIKey: values["api_key"],
+		Model:  values["model"],
+	}
+	if record.APIKey == "" {
+		return record, fmt.Errorf("api_key is empty")
+	}
+	if record.Model == "" {
+		return record, fmt.Errorf("model is empty")
+	}
+	timestamp, err := parseCSVTimestamp(values["timestamp"], opts)
+	if err != nil {
+		return record, err
+	}
+	record.Timestamp = timestamp
+	record.Source = values["source"]
+	record.AuthIndex = values["auth_index"]
+	record.Purpose = values["purpose"]
+	record.RequestID = values["request_id"]
+	record.ErrorType = values["error_type"]
+	record.ClientIP = values["client_ip"]
+	record.UserAgent = values["user_agent"]
+	record.Endpoint = values["endpoint"]
+	record.InstanceID = values["instance_id"]
+	record.Provider = values["provider"]
+	if raw := values["status_code"]; raw != "" {
+		if record.StatusCode, err = strconv.Atoi(raw); err != nil || record.StatusCode < 0 {
+			return record, fmt.Errorf("status_code: invalid status %q", raw)
+		}
+	}
+	if raw := values["failed"]; raw != "" {
+		if record.Failed, err = strconv.ParseBool(raw); err != nil {
+			return record, fmt.Errorf("failed: invalid boolean %q", raw)
+		}
+	}
+	tokenFields := []struct {
+		name string
+		dst  *int64
+	}{
+		{"input_tokens", &record.Tokens.InputTokens},
+		{"output_tokens", &record.Tokens.OutputTokens},
+		{"reasoning_tokens", &record.Tokens.ReasoningTokens},
+		{"cached_tokens", &record.Tokens.CachedTokens},
+		{"total_tokens", &record.Tokens.TotalTokens},
+		{"cost_microdollars", &record.CostMicrodollars},
+		{"duration_ms", &record.DurationMS},
+	}
+	for _, field := range tokenFields {
+		raw := values[field.name]
+		if raw == "" {
+			continue
+		}
+		value, errParse := strconv.ParseInt(raw, 10, 64)
+		if errParse != nil || value < 0 {
+			return record, fmt.Errorf("%s: invalid count %q", field.name, raw)
+		}
+		*field.dst = value
+	}
+	record.Tokens = normaliseTokenStats(record.Tokens)
+	if values["cost_microdollars"] == "" {
+		record.CostMicrodollars = EstimateCost(record.Provider, record.Model, record.Tokens)
+	}
+	return record, nil
+}
+
+func parseCSVTimestamp(raw string, opts CSVImportOptions) (time.Time, error) {
+	if raw == "" {
+		return time.Time{}, fmt.Errorf("timestamp is empty")
+	}
+	layout := strings.TrimSpace(opts.TimestampLayout)
+	switch layout {
+	case "unix", "unix_ms":
+		value, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("timestamp: invalid epoch %q", raw)
+		}
+		if layout == "unix_ms" {
+			return time.UnixMilli(value).UTC(), nil
+		}
+		return time.Unix(value, 0).UTC(), nil
+	case "":
+		layout = time.RFC3339
+	}
+	location := opts.Location
+	if location == nil {
+		location = time.UTC
+	}
+	timestamp, err := time.ParseInLocation(layout, raw, location)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("timestamp: %q does not match layout %q", raw, layout)
+	}
+	return timestamp, nil
+}
+
+// SnapshotFromRecords groups flat records into a snapshot suitable for MergeSnapshot.
+func SnapshotFromRecords(records []FlatRecord) StatisticsSnapshot {
+	snapshot := StatisticsSnapshot{APIs: make(map[string]APISnapshot)}
+	for _, record := range records {
+		apiSnapshot, ok := snapshot.APIs[record.APIKey]
+		if !ok {
+			apiSnapshot = APISnapshot{Models: make(map[string]ModelSnapshot)}
+		}
+		modelSnapshot := apiSnapshot.Models[record.Model]
+		modelSnapshot.TotalRequests++
+		modelSnapshot.TotalTokens += record.Tokens.TotalTokens
+		modelSnapshot.TotalCostMicrodollars += record.CostMicrodollars
+		modelSnapshot.Details = append(modelSnapshot.Details, record.RequestDetail)
+		apiSnapshot.Models[record.Model] = modelSnapshot
+		apiSnapshot.TotalRequests++
+		apiSnapshot.TotalTokens += record.Tokens.TotalTokens
+		apiSnapshot.TotalCostMicrodollars += record.CostMicrodollars
+		snapshot.APIs[record.APIKey] = apiSnapshot
+
+		snapshot.TotalRequests++
+		if record.Failed {
+			snapshot.FailureCount++
+		} else {
+			snapshot.SuccessCount++
+		}
+		snapshot.TotalTokens += record.Tokens.TotalTokens
+		snapshot.TotalCostMicrodollars += record.CostMicrodollars
+	}
+	return snapshot
+}
@@ -0,0 +1,110 @@
+package usage
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+func TestParseCSVRecordsMapsColumnsAndRejectsInvalidRows(t *testing.T) {
+	input := "\ufeffKey,Model Name,When,prompt,completion,failed\n" +
+		"team-a,gpt-4o,2025-03-01 10:00:00,100,20,false\n" +
+		"team-a,gpt-4o,not-a-date,1,1,false\n" +
+		"team-b,,2025-03-01 11:00:00,5,5,false\n" +
+		"team-b,claude-sonnet,2025-03-01 12:00:00,-3,5,false\n" +
+		"team-b,claude-sonnet,2025-03-01 12:30:00,7,,true\n"
+	records, rejected, err := ParseCSVRecords(strings.NewReader(input), CSVImportOptions{
+		Columns: map[string]string{
+			"Key":        "api_key",
+			"Model Name": "model",
+			"When":       "timestamp",
+			"prompt":     "input_tokens",
+			"completion": "output_tokens",
+		},
+		Defaults:        map[string]string{"source": "spreadsheet"},
+		TimestampLayout: "2006-01-02 15:04:05",
+	})
+	if err != nil {
+		t.Fatalf("ParseCSVRecords() error = %v", err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("records = %d, want 2", len(records))
+	}
+	first := records[0]
+	if first.APIKey != "team-a" || first.Model != "gpt-4o" || first.Source != "spreadsheet" {
+		t.Fatalf("unexpected first record: %+v", first)
+	}
+	if want := time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC); !first.Timestamp.Equal(want) {
+		t.Fatalf("timestamp = %v, want %v", first.Timestamp, want)
+	}
+	if first.Tokens.TotalTokens != 120 {
+		t.Fatalf("total tokens = %d, want 120", first.Tokens.TotalTokens)
+	}
+	if !records[1].Failed {
+		t.Fatalf("second record should be failed: %+v", records[1])
+	}
+
+	wantRows := []int{3, 4, 5}
+	if len(rejected) != len(wantRows) {
+		t.Fatalf("rejected = %+v, want rows %v", rejected, wantRows)
+	}
+	for i, row := range wantRows {
+		if rejected[i].Row != row {
+			t.Fatalf("rejected[%d].Row = %d, want %d", i, rejected[i].Row, row)
+		}
+	}
+}
+
+func TestParseCSVRecordsRequiresCoreColumns(t *testing.T) {
+	_, _, err := ParseCSVRecords(strings.NewReader("api_key,timestamp\nk,2025-01-01T00:00:00Z\n"), CSVImportOptions{})
+	if err == nil || !strings.Contains(err.Error(), "model") {
+		t.Fatalf("expected missing model error, got %v", err)
+	}
+	records, _, err := ParseCSVRecords(strings.NewReader("api_key,timestamp\nk,2025-01-01T00:00:00Z\n"), CSVImportOptions{
+		Defaults: map[string]string{"model": "legacy"},
+	})
+	if err != nil || len(records) != 1 || records[0].Model != "legacy" {
+		t.Fatalf("default model not applied: records=%+v err=%v", records, err)
+	}
+}
+
+func TestCSVImportMergeDeduplicatesRows(t *testing.T) {
+	input := "api_key,model,timestamp,input_tokens\n" +
+		"k,m,2025-01-01T00:00:00Z,10\n" +
+		"k,m,2025-01-01T00:00:00Z,10\n" +
+		"k,m,2025-01-01T00:01:00Z,10\n"
+	records, rejected, err := ParseCSVRecords(strings.NewReader(input), CSVImportOptions{})
+	if err != nil || len(rejected) != 0 {
+		t.Fatalf("parse failed: err=%v rejected=%+v", err, rejected)
+	}
+	stats := NewRequestStatistics()
+	result := stats.MergeSnapshot(SnapshotFromRecords(records))
+	if result.Added != 2 || result.Skipped != 1 {
+		t.Fatalf("merge result = %+v, want added 2 skipped 1", result)
+	}
+	result = stats.MergeSnapshot(SnapshotFromRecords(records))
+	if result.Added != 0 || result.Skipped != 3 {
+		t.Fatalf("re-import result = %+v, want added 0 skipped 3", result)
+	}
+}
+
+func TestParseCSVRecordsEstimatesMissingCost(t *testing.T) {
+	SetPricing([]config.ModelPrice{{Model: "m", InputPerMillion: 1, OutputPerMillion: 10}})
+	t.Cleanup(func() { SetPricing(nil) })
+
+	input := "api_key,model,timestamp,input_tokens,output_tokens,cost_microdollars\n" +
+		"k,m,2025-01-01T00:00:00Z,1000,100,\n" +
+		"k,m,2025-01-01T00:01:00Z,1000,100,7\n"
+	records, _, err := ParseCSVRecords(strings.NewReader(input), CSVImportOptions{})
+	if err != nil || len(records) != 2 {
+		t.Fatalf("records=%+v err=%v", records, err)
+	}
+	if records[0].CostMicrodollars != 2000 {
+		t.Fatalf("estimated cost = %d, want 2000", records[0].CostMicrodollars)
+	}
+	if records[1].CostMicrodollars != 7 {
+		t.Fatalf("recorded cost = %d, want 7 kept", records[1].CostMicrodollars)
+	}
+}
@@ -0,0 +1,131 @@
+package usage
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// ExploreDimensions lists the dimensions a cost exploration can drill down along. Requests
+// recorded before the provider was stored have an empty provider.
+var ExploreDimensions = []string{"provider", "api_key", "model", "source", "auth_index", "day"}
+
+// exploreMaxDepth bounds the length of a drill-down path.
+const exploreMaxDepth = 4
+
+// ExploreNode aggregates the records under one node of a drill-down path.
+type ExploreNode struct {
+	Value            string `json:"value"`
+	Requests         int64  `json:"requests"`
+	TotalTokens      int64  `json:"total_tokens"`
+	CostMicrodollars int64  `json:"cost_microdollars"`
+	// Share is the node's fraction of its parent's cost, or of its parent's requests when
+	// the parent has no cost.
+	Share float64 `json:"share"`
+}
+
+// Exploration is one level of a cost drill-down: the selected parent node and a page of its
+// children along the next dimension of the path.
+type Exploration struct {
+	Path       []string      `json:"path"`
+	Selected   []string      `json:"selected"`
+	Dimension  string        `json:"dimension"`
+	Parent     ExploreNode   `json:"parent"`
+	Children   []ExploreNode `json:"children"`
+	TotalNodes int           `json:"total_children"`
+	NextOffset *int          `json:"next_offset,omitempty"`
+}
+
+// Explore aggregates records for the node reached by following selected values along path,
+// and returns its children along the next dimension ordered by cost, tokens and value.
+// Day values use loc (UTC when nil). Children always sum to the parent for the same records.
+func Explore(records []FlatRecord, path, selected []string, loc *time.Location, limit, offset int) (Exploration, error) {
+	if len(path) == 0 || len(path) > exploreMaxDepth {
+		return Exploration{}, fmt.Errorf("path must list 1 to %d dimensions", exploreMaxDepth)
+	}
+	used := make(map[string]struct{}, len(path))
+	for _, dimension := range path {
+		valid := false
+		for _, known := range ExploreDimensions {
+			if dimension == known {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return Exploration{}, fmt.Errorf("unsupported dimension %q", dimension)
+		}
+		if _, dup := used[dimension]; dup {
+			return Exploration{}, fmt.Errorf("dimension %q repeated in path", dimension)
+		}
+		used[dimension] = struct{}{}
+	}
+	if len(selected) >= len(path) {
+		return Exploration{}, fmt.Errorf("selected %d values for a path of %d dimensions; nothing left to expand", len(selected), len(path))
+	}
+	if loc == nil {
+		loc = time.UTC
+	}
+	valueOf := func(record FlatRecord, dimension string) string {
+		if dimension == "day" {
+			return record.Timestamp.In(loc).Format("2006-01-02")
+		}
+		return record.columnValue(dimension)
+	}
+
+	dimension := path[len(selected)]
+	result := Exploration{Path: path, Selected: selected, Dimension: dimension, Children: []ExploreNode{}}
+	children := make(map[string]*ExploreNode)
+records:
+	for _, record := range records {
+		for i, value := range selected {
+			if valueOf(record, path[i]) != value {
+				continue records
+			}
+		}
+		value := valueOf(record, dimension)
+		child, ok := children[value]
+		if !ok {
+			child = &ExploreNode{Value: value}
+			children[value] = child
+		}
+		for _, node := range []*ExploreNode{&result.Parent, child} {
+			node.Requests++
+			node.TotalTokens += record.Tokens.TotalTokens
+			node.CostMicrodollars += record.CostMicrodollars
+		}
+	}
+	if len(selected) > 0 {
+		result.Parent.Value = selected[len(selected)-1]
+	}
+	result.Parent.Share = 1
+
+	nodes := make([]ExploreNode, 0, len(children))
+	for _, child := range children {
+		switch {
+		case result.Parent.CostMicrodollars > 0:
+			child.Share = float64(child.CostMicrodollars) / float64(result.Parent.CostMicrodollars)
+		case result.Parent.Requests > 0:
+			child.Share = float64(child.Requests) / float64(result.Parent.Requests)
+		}
+		nodes = append(nodes, *child)
+	}
+	sort.Slice(nodes, func(i, j int) bool {
+		if nodes[i].CostMicrodollars != nodes[j].CostMicrodollars {
+			return nodes[i].CostMicrodollars > nodes[j].CostMicrodollars
+		}
+		if nodes[i].TotalTokens != nodes[j].TotalTokens {
+			return nodes[i].TotalTokens > nodes[j].TotalTokens
+		}
+		return nodes[i].Value < nodes[j].Value
+	})
+
+	result.TotalNodes = len(nodes)
+	start := min(offset, len(nodes))
+	end := min(start+limit, len(nodes))
+	result.Children = append(result.Children, nodes[start:end]...)
+	if end < len(nodes) {
+		result.NextOffset = &end
+	}
+	return result, nil
+}
@@ -0,0 +1,74 @@
+package usage
+
+import (
+	"testing"
+	"time"
+)
+
+func TestExploreDrillsDownAndChildrenSumToParent(t *testing.T) {
+	day1 := time.Date(2025, 4, 1, 10, 0, 0, 0, time.UTC)
+	day2 := day1.Add(24 * time.Hour)
+	records := []FlatRecord{
+		{APIKey: "a", Model: "m1", RequestDetail: RequestDetail{Timestamp: day1, CostMicrodollars: 300, Tokens: TokenStats{TotalTokens: 30}}},
+		{APIKey: "b", Model: "m1", RequestDetail: RequestDetail{Timestamp: day2, CostMicrodollars: 100, Tokens: TokenStats{TotalTokens: 10}}},
+		{APIKey: "a", Model: "m2", RequestDetail: RequestDetail{Timestamp: day2, CostMicrodollars: 600, Tokens: TokenStats{TotalTokens: 60}}},
+	}
+	path := []string{"model", "api_key", "day"}
+
+	top, err := Explore(records, path, nil, nil, 10, 0)
+	if err != nil {
+		t.Fatalf("explore: %v", err)
+	}
+	if top.Parent.CostMicrodollars != 1000 || len(top.Children) != 2 || top.Children[0].Value != "m2" || top.Children[0].Share != 0.6 {
+		t.Fatalf("unexpected top level: %+v", top)
+	}
+
+	level, err := Explore(records, path, []string{"m1"}, nil, 1, 0)
+	if err != nil {
+		t.Fatalf("explore m1: %v", err)
+	}
+	if level.Dimension != "api_key" || level.Parent.CostMicrodollars != top.Children[1].CostMicrodollars {
+		t.Fatalf("child level should total its parent node: %+v", level)
+	}
+	if len(level.Children) != 1 || level.Children[0].Value != "a" || level.NextOffset == nil || *level.NextOffset != 1 {
+		t.Fatalf("unexpected first page: %+v", level)
+	}
+	page, err := Explore(records, path, []string{"m1"}, nil, 1, *level.NextOffset)
+	if err != nil {
+		t.Fatalf("explore page 2: %v", err)
+	}
+	if len(page.Children) != 1 || page.Children[0].Value != "b" || page.NextOffset != nil {
+		t.Fatalf("unexpected second page: %+v", page)
+	}
+
+	days, err := Explore(records, path, []string{"m1", "b"}, nil, 10, 0)
+	if err != nil {
+		t.Fatalf("explore days: %v", err)
+	}
+	if len(days.Children) != 1 || days.Children[0].Value != "2025-04-02" {
+		t.Fatalf("unexpected day level: %+v", days)
+	}
+
+	if _, err = Explore(records, path, []string{"m1", "b", "2025-04-02"}, nil, 10, 0); err == nil {
+		t.Fatal("expected an error when nothing is left to expand")
+	}
+	if _, err = Explore(records, []string{"model", "model"}, nil, nil, 10, 0); err == nil {
+		t.Fatal("expected an error for a repeated dimension")
+	}
+}
+
+func TestExploreByProvider(t *testing.T) {
+	now := time.Date(2025, 4, 1, 10, 0, 0, 0, time.UTC)
+	records := []FlatRecord{
+		{APIKey: "a", Model: "m1", RequestDetail: RequestDetail{Timestamp: now, Provider: "claude", CostMicrodollars: 300}},
+		{APIKey: "a", Model: "m2", RequestDetail: RequestDetail{Timestamp: now, Provider: "gemini", CostMicrodollars: 100}},
+		{APIKey: "b", Model: "m1", RequestDetail: RequestDetail{Timestamp: now, Provider: "claude", CostMicrodollars: 200}},
+	}
+	level, err := Explore(records, []string{"provider", "api_key"}, []string{"claude"}, nil, 10, 0)
+	if err != nil {
+		t.Fatalf("explore: %v", err)
+	}
+	if level.Parent.CostMicrodollars != 500 || len(level.Children) != 2 || level.Children[0].Value != "a" {
+		t.Fatalf("unexpected provider level: %+v", level)
+	}
+}
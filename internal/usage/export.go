@@ -0,0 +1,107 @@
+package usage
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+)
+
+// Records returns the recorded request details as flat records ordered by recordLess, with
+// aliased API keys reported under the key they alias. Zero from/to bounds are treated as unbounded; both bounds are inclusive.
+func (s *RequestStatistics) Records(from, to time.Time) []FlatRecord {
+	records := make([]FlatRecord, 0)
+	if s == nil {
+		return records
+	}
+
+	s.mu.RLock()
+	for apiName, stats := range s.apis {
+		apiName = CanonicalAPIKey(apiName)
+		for modelName, modelStatsValue := range stats.Models {
+			for _, detail := range modelStatsValue.Details {
+				if !from.IsZero() && detail.Timestamp.Before(from) {
+					continue
+				}
+				if !to.IsZero() && detail.Timestamp.After(to) {
+					continue
+				}
+				records = append(records, FlatRecord{APIKey: apiName, Model: modelName, RequestDetail: detail})
+			}
+		}
+	}
+	s.mu.RUnlock()
+
+	sort.SliceStable(records, func(i, j int) bool { return recordLess(records[i], records[j]) })
+	return records
+}
+
+// recordLess orders records by timestamp, API key, model and request ID. Records are
+// gathered from maps, so the order must not depend on their input order for offset
+// pagination to be stable.
+func recordLess(a, b FlatRecord) bool {
+	if !a.Timestamp.Equal(b.Timestamp) {
+		return a.Timestamp.Before(b.Timestamp)
+	}
+	if a.APIKey != b.APIKey {
+		return a.APIKey < b.APIKey
+	}
+	if a.Model != b.Model {
+		return a.Model < b.Model
+	}
+	return a.RequestID < b.RequestID
+}
+
+// csvSchemaVersionPrefix starts the comment line that leads a CSV record export.
+const csvSchemaVersionPrefix = "# schema_version: "
+
+// WriteRecordsCSV writes records as CSV: a "# schema_version: N" comment line, then a header
+// row listing every schema column.
+func WriteRecordsCSV(w io.Writer, records []FlatRecord) error {
+	if _, err := fmt.Fprintf(w, "%s%d\n", csvSchemaVersionPrefix, SchemaVersion); err != nil {
+		return err
+	}
+	writer := csv.NewWriter(w)
+	row := make([]string, len(recordColumns))
+	for i, column := range recordColumns {
+		row[i] = column.Name
+	}
+	if err := writer.Write(row); err != nil {
+		return err
+	}
+	for _, record := range records {
+		for i, column := range recordColumns {
+			row[i] = column.value(record)
+		}
+		if err := writer.Write(row); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// WriteRecordsJSONL writes records as newline-delimited JSON objects, after a first line
+// holding only the schema version: {"schema_version":N}.
+func WriteRecordsJSONL(w io.Writer, records []FlatRecord) error {
+	encoder := json.NewEncoder(w)
+	if err := encoder.Encode(map[string]int{"schema_version": SchemaVersion}); err != nil {
+		return err
+	}
+	for _, record := range records {
+		if err := encoder.Encode(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// columnValue renders the named schema column of r, or "" for an unknown column.
+func (r FlatRecord) columnValue(name string) string {
+	if column, ok := recordColumnsByName[name]; ok {
+		return column.value(r)
+	}
+	return ""
+}
@@ -0,0 +1,83 @@
+package usage
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+)
+
+func TestWriteRecordsCSVRoundTrip(t *testing.T) {
+	stats := NewRequestStatistics()
+	stats.MergeSnapshot(SnapshotFromRecords([]FlatRecord{
+		{APIKey: "k1", Model: "m1", RequestDetail: RequestDetail{
+			Timestamp:  time.Date(2025, 1, 1, 0, 0, 0, 123, time.UTC),
+			Source:     "acct,with comma",
+			AuthIndex:  "3",
+			Failed:     true,
+			Purpose:    "probe",
+			Tokens:     TokenStats{InputTokens: 1, OutputTokens: 2, ReasoningTokens: 3, CachedTokens: 4, TotalTokens: 10},
+			DurationMS: 250,
+			StatusCode: 503,
+			ErrorType:  "upstream",
+		}},
+		{APIKey: "k2", Model: "m2", RequestDetail: RequestDetail{
+			Timestamp: time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC),
+			Tokens:    TokenStats{InputTokens: 5, TotalTokens: 5},
+		}},
+	}))
+
+	records := stats.Records(time.Time{}, time.Time{})
+	var buf strings.Builder
+	if err := WriteRecordsCSV(&buf, records); err != nil {
+		t.Fatalf("WriteRecordsCSV() error = %v", err)
+	}
+	if want := fmt.Sprintf("# schema_version: %d\n", SchemaVersion); !strings.HasPrefix(buf.String(), want) {
+		t.Fatalf("csv export should start with %q: %q", want, buf.String())
+	}
+	parsed, rejected, err := ParseCSVRecords(strings.NewReader(buf.String()), CSVImportOptions{TimestampLayout: time.RFC3339Nano})
+	if err != nil || len(rejected) != 0 {
+		t.Fatalf("re-import failed: err=%v rejected=%+v", err, rejected)
+	}
+	if len(parsed) != len(records) {
+		t.Fatalf("round trip returned %d records, want %d", len(parsed), len(records))
+	}
+	for i := range records {
+		if !parsed[i].Timestamp.Equal(records[i].Timestamp) {
+			t.Fatalf("record %d timestamp = %v, want %v", i, parsed[i].Timestamp, records[i].Timestamp)
+		}
+		parsed[i].Timestamp = records[i].Timestamp
+		if parsed[i] != records[i] {
+			t.Fatalf("record %d = %+v, want %+v", i, parsed[i], records[i])
+		}
+	}
+
+	var jsonl strings.Builder
+	if err := WriteRecordsJSONL(&jsonl, records); err != nil {
+		t.Fatalf("WriteRecordsJSONL() error = %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(jsonl.String()), "\n")
+	if want := fmt.Sprintf(`{"schema_version":%d}`, SchemaVersion); len(lines) != len(records)+1 || lines[0] != want {
+		t.Fatalf("jsonl export should start with %s and hold one line per record: %q", want, lines)
+	}
+
+	ranged := stats.Records(time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC), time.Time{})
+	if len(ranged) != 1 || ranged[0].APIKey != "k2" {
+		t.Fatalf("from filter returned %+v", ranged)
+	}
+}
+
+func TestRecordsOrderIsStableForEqualTimestamps(t *testing.T) {
+	ts := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	stats := NewRequestStatistics()
+	for _, id := range []string{"c", "a", "b"} {
+		stats.Record(context.Background(), coreusage.Record{APIKey: "k", Model: "m", RequestedAt: ts, RequestID: id})
+	}
+	records := stats.Records(time.Time{}, time.Time{})
+	if len(records) != 3 || records[0].RequestID != "a" || records[1].RequestID != "b" || records[2].RequestID != "c" {
+		t.Fatalf("records are not ordered by request ID: %+v", records)
+	}
+}
@@ -0,0 +1,346 @@
+package usage
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	fileStoreActive = "usage.jsonl"
+	fileStorePrefix = "usage-"
+)
+
+// RecordSink receives every client request recorded by RequestStatistics.
+type RecordSink interface {
+	Append(record FlatRecord) error
+}
+
+// FileStore persists usage records as newline-delimited JSON in an append-only file that is
+// rotated by size or by UTC day. Rotated files are named usage-<timestamp>.jsonl and are
+// optionally gzipped.
+type FileStore struct {
+	dir         string
+	maxBytes    int64
+	rotateDaily bool
+	compress    bool
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+	day  string
+	wg   sync.WaitGroup
+}
+
+// NewFileStore opens, creating if needed, the active file in cfg.Dir.
+func NewFileStore(cfg config.UsageStore) (*FileStore, error) {
+	dir := strings.TrimSpace(cfg.Dir)
+	if dir == "" {
+		return nil, errors.New("usage store: dir is required for the jsonl backend")
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("usage store: create dir: %w", err)
+	}
+	s := &FileStore{
+		dir:         dir,
+		maxBytes:    int64(cfg.RotateSizeMB) << 20,
+		rotateDaily: cfg.RotateDaily,
+		compress:    cfg.Compress,
+	}
+	if err := s.openActive(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *FileStore) openActive() error {
+	path := filepath.Join(s.dir, fileStoreActive)
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("usage store: open %s: %w", path, err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return fmt.Errorf("usage store: stat %s: %w", path, err)
+	}
+	s.file = file
+	s.size = info.Size()
+	s.day = info.ModTime().UTC().Format("2006-01-02")
+	return nil
+}
+
+// Append writes record as one line, rotating the active file first when it is due.
+func (s *FileStore) Append(record FlatRecord) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.file == nil {
+		return errors.New("usage store: closed")
+	}
+	today := time.Now().UTC().Format("2006-01-02")
+	if s.size > 0 && ((s.maxBytes > 0 && s.size+int64(len(line)) > s.maxBytes) || (s.rotateDaily && today != s.day)) {
+		if err = s.rotate(); err != nil {
+			return err
+		}
+	}
+	n, err := s.file.Write(line)
+	s.size += int64(n)
+	s.day = today
+	return err
+}
+
+// rotate renames the active file out of the way and opens a fresh one. Callers hold s.mu.
+func (s *FileStore) rotate() error {
+	if err := s.file.Close(); err != nil {
+		return fmt.Errorf("usage store: close: %w", err)
+	}
+	s.file = nil
+	rotated := filepath.Join(s.dir, fileStorePrefix+time.Now().UTC().Format("20060102T150405.000000000")+".jsonl")
+	if err := os.Rename(filepath.Join(s.dir, fileStoreActive), rotated); err != nil {
+		return fmt.Errorf("usage store: rotate: %w", err)
+	}
+	if s.compress {
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			if err := gzipFile(rotated); err != nil {
+				log.Warnf("usage store: compress %s: %v", rotated, err)
+			}
+		}()
+	}
+	return s.openActive()
+}
+
+// gzipFile replaces path with path.gz.
+func gzipFile(path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = src.Close() }()
+	tmp := path + ".gz.tmp"
+	dst, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(dst)
+	_, err = io.Copy(zw, src)
+	if errClose := zw.Close(); err == nil {
+		err = errClose
+	}
+	if errClose := dst.Close(); err == nil {
+		err = errClose
+	}
+	if err == nil {
+		err = os.Rename(tmp, path+".gz")
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	return os.Remove(path)
+}
+
+// Close waits for pending compression and closes the active file.
+func (s *FileStore) Close() error {
+	s.mu.Lock()
+	var err error
+	if s.file != nil {
+		err = s.file.Close()
+		s.file = nil
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+	return err
+}
+
+// ReadFileStore loads the records of the store in dir between from and to without opening it
+// for writing, so it can run while a proxy keeps appending to the same directory.
+func ReadFileStore(dir string, from, to time.Time) ([]FlatRecord, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, fmt.Errorf("usage store: %w", err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("usage store: %s is not a directory", dir)
+	}
+	return (&FileStore{dir: dir}).LoadRange(from, to)
+}
+
+// LoadAll reads back every record in the store, oldest file first.
+func (s *FileStore) LoadAll() ([]FlatRecord, error) {
+	return s.LoadRange(time.Time{}, time.Time{})
+}
+
+// LoadRange reads back the records whose timestamp falls within from/to. Zero bounds are
+// unbounded. A request whose tokens were updated is returned once with its latest counts.
+// Lines that cannot be decoded, such as a line cut short by a crash, are skipped with a
+// warning.
+func (s *FileStore) LoadRange(from, to time.Time) ([]FlatRecord, error) {
+	records, _, err := s.load(from, to)
+	return records, err
+}
+
+// load reads back the records within from/to and every reset marker, oldest file first.
+func (s *FileStore) load(from, to time.Time) ([]FlatRecord, []ResetMarker, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, nil, fmt.Errorf("usage store: read dir: %w", err)
+	}
+	seen := make(map[string]bool)
+	var names []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, fileStorePrefix) {
+			continue
+		}
+		base := strings.TrimSuffix(name, ".gz")
+		if !strings.HasSuffix(base, ".jsonl") || seen[base] {
+			continue
+		}
+		// A file being compressed exists in both forms; its plain copy is complete.
+		seen[base] = true
+		if strings.HasSuffix(name, ".gz") {
+			if _, errStat := os.Stat(filepath.Join(s.dir, base)); errStat == nil {
+				name = base
+			}
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	names = append(names, fileStoreActive)
+
+	contents := &storeContents{records: make([]FlatRecord, 0)}
+	for _, name := range names {
+		if err = readStoreFile(filepath.Join(s.dir, name), from, to, contents); err != nil {
+			return nil, nil, err
+		}
+	}
+	return latestByRequestID(contents.records), contents.resets, nil
+}
+
+// AppendReset persists a reset marker so the reset is replayed when the store is loaded.
+func (s *FileStore) AppendReset(marker ResetMarker) error {
+	line, err := json.Marshal(storeLine{Reset: &marker})
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.file == nil {
+		return errors.New("usage store: closed")
+	}
+	n, err := s.file.Write(line)
+	s.size += int64(n)
+	return err
+}
+
+// storeLine is one line of the store: a usage record or, when Reset is set, a reset marker.
+type storeLine struct {
+	Reset *ResetMarker `json:"reset,omitempty"`
+	*FlatRecord
+}
+
+type storeContents struct {
+	records []FlatRecord
+	resets  []ResetMarker
+}
+
+// latestByRequestID keeps the last line written for each request ID. Late token updates are
+// appended after the request they update and never lower its counts.
+func latestByRequestID(records []FlatRecord) []FlatRecord {
+	last := make(map[string]int, len(records))
+	for i, record := range records {
+		if record.RequestID != "" {
+			last[record.RequestID] = i
+		}
+	}
+	out := records[:0]
+	for i, record := range records {
+		if record.RequestID != "" && last[record.RequestID] != i {
+			continue
+		}
+		out = append(out, record)
+	}
+	return out
+}
+
+func readStoreFile(path string, from, to time.Time, contents *storeContents) error {
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("usage store: open %s: %w", path, err)
+	}
+	defer func() { _ = file.Close() }()
+
+	var reader io.Reader = file
+	if strings.HasSuffix(path, ".gz") {
+		zr, errGzip := gzip.NewReader(file)
+		if errGzip != nil {
+			log.Warnf("usage store: skipping unreadable %s: %v", path, errGzip)
+			return nil
+		}
+		defer func() { _ = zr.Close() }()
+		reader = zr
+	}
+
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 0, 64*1024), 4<<20)
+	line := 0
+	for scanner.Scan() {
+		line++
+		raw := scanner.Bytes()
+		if len(raw) == 0 {
+			continue
+		}
+		var record FlatRecord
+		decoded := storeLine{FlatRecord: &record}
+		if err = json.Unmarshal(raw, &decoded); err != nil {
+			log.Warnf("usage store: skipping malformed line %d of %s", line, path)
+			continue
+		}
+		if decoded.Reset != nil {
+			contents.resets = append(contents.resets, *decoded.Reset)
+			continue
+		}
+		if record.Model == "" {
+			log.Warnf("usage store: skipping malformed line %d of %s", line, path)
+			continue
+		}
+		if !from.IsZero() && record.Timestamp.Before(from) {
+			continue
+		}
+		if !to.IsZero() && record.Timestamp.After(to) {
+			continue
+		}
+		contents.records = append(contents.records, record)
+	}
+	if err = scanner.Err(); err != nil {
+		log.Warnf("usage store: stopped reading %s after line %d: %v", path, line, err)
+	}
+	return nil
+}
@@ -0,0 +1,148 @@
+package usage
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+)
+
+func TestFileStoreRotatesAndReloads(t *testing.T) {
+	dir := t.TempDir()
+	cfg := config.UsageStore{Backend: "jsonl", Dir: dir, Compress: true}
+	stats := NewRequestStatistics()
+	persistence, err := OpenUsageStore(cfg, stats)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	// Force a rotation after every record.
+	persistence.store.maxBytes = 1
+
+	base := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < 3; i++ {
+		stats.Record(context.Background(), coreusage.Record{
+			APIKey:      "k",
+			Model:       "m",
+			RequestedAt: base.Add(time.Duration(i) * time.Minute),
+			Detail:      coreusage.Detail{InputTokens: int64(i + 1)},
+		})
+	}
+	stats.Record(context.Background(), coreusage.Record{APIKey: coreusage.SystemAPIKey, Model: "m", Purpose: "probe"})
+	if err = persistence.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	matches, _ := filepath.Glob(filepath.Join(dir, "usage-*.jsonl.gz"))
+	if len(matches) != 2 {
+		t.Fatalf("expected two compressed rotated files, got %v", matches)
+	}
+	// Simulate a crash that left a partial last line.
+	active, err := os.OpenFile(filepath.Join(dir, fileStoreActive), os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		t.Fatalf("open active: %v", err)
+	}
+	_, _ = active.WriteString(`{"api_key":"k","model":"m","timest`)
+	_ = active.Close()
+
+	reloaded := NewRequestStatistics()
+	persistence, err = OpenUsageStore(cfg, reloaded)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer func() { _ = persistence.Close() }()
+	if got := reloaded.Snapshot().TotalRequests; got != 3 {
+		t.Fatalf("reloaded %d requests, want 3 (system usage is not persisted)", got)
+	}
+	ranged, err := persistence.store.LoadRange(base.Add(30*time.Second), time.Time{})
+	if err != nil || len(ranged) != 2 {
+		t.Fatalf("range load = %d records, %v", len(ranged), err)
+	}
+
+	// Reloading the same files into statistics that already hold them must not double count.
+	if result := reloaded.MergeSnapshot(SnapshotFromRecords(ranged)); result.Added != 0 || result.Skipped != 2 {
+		t.Fatalf("unexpected merge result: %+v", result)
+	}
+}
+
+func TestOpenUsageStoreBackends(t *testing.T) {
+	if store, err := OpenUsageStore(config.UsageStore{}, NewRequestStatistics()); store != nil || err != nil {
+		t.Fatalf("memory backend should open nothing: %v %v", store, err)
+	}
+	if _, err := OpenUsageStore(config.UsageStore{Backend: "sqlite"}, NewRequestStatistics()); err == nil || !strings.Contains(err.Error(), "unknown backend") {
+		t.Fatalf("expected unknown backend error, got %v", err)
+	}
+	if _, err := OpenUsageStore(config.UsageStore{Backend: "jsonl"}, NewRequestStatistics()); err == nil {
+		t.Fatal("expected an error without dir")
+	}
+}
+
+func TestFileStoreKeepsLatestTokenUpdate(t *testing.T) {
+	dir := t.TempDir()
+	stats := NewRequestStatistics()
+	persistence, err := OpenUsageStore(config.UsageStore{Backend: "jsonl", Dir: dir}, stats)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	record := coreusage.Record{APIKey: "k", Model: "m", RequestedAt: time.Now(), RequestID: "r1", Detail: coreusage.Detail{InputTokens: 1}}
+	stats.Record(context.Background(), record)
+	record.Update, record.Detail = true, coreusage.Detail{InputTokens: 1, OutputTokens: 9}
+	stats.Record(context.Background(), record)
+	_ = persistence.Close()
+
+	reloaded := NewRequestStatistics()
+	persistence, err = OpenUsageStore(config.UsageStore{Backend: "jsonl", Dir: dir}, reloaded)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer func() { _ = persistence.Close() }()
+	if snapshot := reloaded.Snapshot(); snapshot.TotalRequests != 1 || snapshot.TotalTokens != 10 {
+		t.Fatalf("reloaded requests=%d tokens=%d, want 1 and 10", snapshot.TotalRequests, snapshot.TotalTokens)
+	}
+}
+
+func TestReadFileStoreRequiresExistingDir(t *testing.T) {
+	if _, err := ReadFileStore(filepath.Join(t.TempDir(), "missing"), time.Time{}, time.Time{}); err == nil {
+		t.Fatal("expected an error for a missing directory")
+	}
+	records, err := ReadFileStore(t.TempDir(), time.Time{}, time.Time{})
+	if err != nil || len(records) != 0 {
+		t.Fatalf("empty store = %v, %v", records, err)
+	}
+}
+
+func TestFileStorePersistsImports(t *testing.T) {
+	dir := t.TempDir()
+	cfg := config.UsageStore{Backend: "jsonl", Dir: dir}
+	stats := NewRequestStatistics()
+	persistence, err := OpenUsageStore(cfg, stats)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	base := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	stats.Record(context.Background(), coreusage.Record{APIKey: "sk-live-secret", Model: "m", RequestedAt: base})
+	imported := StatisticsSnapshot{APIs: map[string]APISnapshot{"sk-imported-secret": {Models: map[string]ModelSnapshot{
+		"m": {Details: []RequestDetail{{Timestamp: base.Add(time.Minute), Tokens: TokenStats{InputTokens: 5}}}},
+	}}}}
+	if result := stats.MergeSnapshot(imported); result.Added != 1 {
+		t.Fatalf("import added %d, want 1", result.Added)
+	}
+	if err = persistence.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	reloaded := NewRequestStatistics()
+	reopened, err := OpenUsageStore(cfg, reloaded)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer func() { _ = reopened.Close() }()
+	apis := reloaded.Snapshot().APIs
+	if len(apis) != 2 || apis["sk-live-secret"].TotalRequests != 1 || apis["sk-imported-secret"].TotalRequests != 1 {
+		t.Fatalf("reloaded keys = %v; want the recorded and the imported request", apis)
+	}
+}
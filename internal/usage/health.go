@@ -0,0 +1,208 @@
+package usage
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+)
+
+const (
+	defaultHealthWindow          = 10 * time.Minute
+	defaultHealthMinRequests     = 20
+	defaultHealthMaxFailureRatio = 0.5
+	// healthRefreshInterval bounds how often the failure rates behind credential selection are
+	// recomputed.
+	healthRefreshInterval = 10 * time.Second
+)
+
+// HealthCounts summarises the recent requests served by a credential.
+type HealthCounts struct {
+	Requests     int64   `json:"requests"`
+	Failures     int64   `json:"failures"`
+	FailureRatio float64 `json:"failure_ratio"`
+	// Unhealthy reports whether the counts breach the configured health thresholds.
+	Unhealthy bool `json:"unhealthy,omitempty"`
+}
+
+// AuthHealth summarises the recent requests served by one credential, overall and per model.
+type AuthHealth struct {
+	HealthCounts
+	Source string                  `json:"source,omitempty"`
+	Models map[string]HealthCounts `json:"models"`
+}
+
+// HealthPolicy holds the resolved credential health thresholds.
+type HealthPolicy struct {
+	Enabled         bool    `json:"enabled"`
+	Window          string  `json:"window"`
+	MinRequests     int64   `json:"min_requests"`
+	MaxFailureRatio float64 `json:"max_failure_ratio"`
+	window          time.Duration
+}
+
+// healthState is an immutable view of the credential health thresholds and the failure
+// rates last computed under them.
+type healthState struct {
+	policy    HealthPolicy
+	rates     map[string]AuthHealth
+	refreshed time.Time
+}
+
+var (
+	credentialHealth atomic.Pointer[healthState]
+	healthRefreshing atomic.Bool
+)
+
+func init() {
+	credentialHealth.Store(&healthState{policy: resolveHealthPolicy(config.CredentialHealth{})})
+}
+
+func resolveHealthPolicy(cfg config.CredentialHealth) HealthPolicy {
+	policy := HealthPolicy{
+		Enabled:         cfg.Enabled,
+		MinRequests:     cfg.MinRequests,
+		MaxFailureRatio: cfg.MaxFailureRatio,
+		window:          defaultHealthWindow,
+	}
+	if window, err := time.ParseDuration(cfg.Window); err == nil && window > 0 {
+		policy.window = window
+	}
+	if policy.MinRequests <= 0 {
+		policy.MinRequests = defaultHealthMinRequests
+	}
+	if policy.MaxFailureRatio <= 0 || policy.MaxFailureRatio > 1 {
+		policy.MaxFailureRatio = defaultHealthMaxFailureRatio
+	}
+	policy.Window = policy.window.String()
+	return policy
+}
+
+// SetCredentialHealth replaces the credential health thresholds. An invalid window falls back
+// to the default.
+func SetCredentialHealth(cfg config.CredentialHealth) {
+	credentialHealth.Store(&healthState{policy: resolveHealthPolicy(cfg)})
+}
+
+// CredentialHealthPolicy returns the thresholds in effect.
+func CredentialHealthPolicy() HealthPolicy {
+	return credentialHealth.Load().policy
+}
+
+// CredentialUnhealthy reports whether the credential identified by authIndex has failed too
+// often for model over the health window. Credentials are judged per model so one that only
+// serves some models is not penalised for the others; an empty model uses the overall counts.
+// It runs on every credential pick, so it only reads the last computed rates and leaves
+// rescanning the statistics to a background refresh: results lag recorded usage by up to
+// healthRefreshInterval plus the time a rescan takes, and recover as the window rolls.
+func CredentialUnhealthy(authIndex, model string) bool {
+	return credentialUnhealthy(defaultRequestStatistics, authIndex, model)
+}
+
+func credentialUnhealthy(stats *RequestStatistics, authIndex, model string) bool {
+	state := credentialHealth.Load()
+	if state.rates == nil || time.Since(state.refreshed) >= healthRefreshInterval {
+		refreshCredentialHealth(stats, state)
+	}
+	health, ok := state.rates[authIndex]
+	if !ok {
+		return false
+	}
+	if model == "" {
+		return health.Unhealthy
+	}
+	return health.Models[model].Unhealthy
+}
+
+// refreshCredentialHealth recomputes the failure rates of state in the background unless a
+// refresh is already running. The result is dropped when the thresholds changed meanwhile.
+func refreshCredentialHealth(stats *RequestStatistics, state *healthState) {
+	if !healthRefreshing.CompareAndSwap(false, true) {
+		return
+	}
+	go func() {
+		defer healthRefreshing.Store(false)
+		now := time.Now()
+		credentialHealth.CompareAndSwap(state, &healthState{
+			policy:    state.policy,
+			rates:     stats.failureRates(state.policy, now),
+			refreshed: now,
+		})
+	}()
+}
+
+// FailureRates counts the requests and failures per auth_index over the trailing window,
+// marking counts that breach the current health thresholds. Requests without an auth_index,
+// system requests and requests that failed for reasons unrelated to the credential are ignored.
+func (s *RequestStatistics) FailureRates(window time.Duration) map[string]AuthHealth {
+	policy := CredentialHealthPolicy()
+	if window > 0 {
+		policy.window = window
+	}
+	return s.failureRates(policy, time.Now())
+}
+
+func (s *RequestStatistics) failureRates(policy HealthPolicy, now time.Time) map[string]AuthHealth {
+	rates := make(map[string]AuthHealth)
+	if s == nil {
+		return rates
+	}
+	cutoff := now.Add(-policy.window)
+	add := func(counts *HealthCounts, failed bool) {
+		counts.Requests++
+		if failed {
+			counts.Failures++
+		}
+	}
+
+	s.mu.RLock()
+	for _, stats := range s.apis {
+		for modelName, modelStatsValue := range stats.Models {
+			for _, detail := range modelStatsValue.Details {
+				if detail.AuthIndex == "" || detail.Timestamp.Before(cutoff) || !judgesCredential(detail) {
+					continue
+				}
+				health, ok := rates[detail.AuthIndex]
+				if !ok {
+					health = AuthHealth{Source: detail.Source, Models: make(map[string]HealthCounts)}
+				}
+				add(&health.HealthCounts, detail.Failed)
+				counts := health.Models[modelName]
+				add(&counts, detail.Failed)
+				health.Models[modelName] = counts
+				rates[detail.AuthIndex] = health
+			}
+		}
+	}
+	s.mu.RUnlock()
+
+	judge := func(counts *HealthCounts) {
+		counts.FailureRatio = float64(counts.Failures) / float64(counts.Requests)
+		counts.Unhealthy = counts.Requests >= policy.MinRequests && counts.FailureRatio >= policy.MaxFailureRatio
+	}
+	for authIndex, health := range rates {
+		judge(&health.HealthCounts)
+		for modelName, counts := range health.Models {
+			judge(&counts)
+			health.Models[modelName] = counts
+		}
+		rates[authIndex] = health
+	}
+	return rates
+}
+
+// judgesCredential reports whether detail says anything about the health of its credential.
+// Failures the client caused (cancellations, invalid requests) or that were not classified as
+// an upstream, rate limit, auth or timeout error are left out. Failures recorded before errors
+// were classified carry no error type and still count.
+func judgesCredential(detail RequestDetail) bool {
+	if !detail.Failed {
+		return true
+	}
+	switch detail.ErrorType {
+	case "", coreusage.ErrorTypeUpstream, coreusage.ErrorTypeRateLimit, coreusage.ErrorTypeAuth, coreusage.ErrorTypeTimeout:
+		return true
+	}
+	return false
+}
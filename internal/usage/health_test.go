@@ -0,0 +1,97 @@
+package usage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+)
+
+func TestFailureRatesJudgesPerModel(t *testing.T) {
+	SetCredentialHealth(config.CredentialHealth{Enabled: true, Window: "10m", MinRequests: 4, MaxFailureRatio: 0.5})
+	t.Cleanup(func() { SetCredentialHealth(config.CredentialHealth{}) })
+
+	now := time.Now()
+	var records []FlatRecord
+	add := func(model string, age time.Duration, failed bool) {
+		records = append(records, FlatRecord{APIKey: "k", Model: model, RequestDetail: RequestDetail{
+			Timestamp: now.Add(-age), AuthIndex: "a1", Source: "acct", Failed: failed, RequestID: time.Duration(len(records)).String(),
+		}})
+	}
+	for i := 0; i < 3; i++ {
+		add("pro", time.Minute, true)
+	}
+	add("pro", time.Minute, false)
+	// A single failure is too small a sample to judge.
+	add("flash", time.Minute, true)
+	// Failures outside the window have rolled off.
+	for i := 0; i < 5; i++ {
+		add("lite", time.Hour, true)
+	}
+	stats := NewRequestStatistics()
+	stats.MergeSnapshot(SnapshotFromRecords(records))
+
+	rates := stats.FailureRates(0)
+	health, ok := rates["a1"]
+	if !ok || health.Requests != 5 || health.Failures != 4 || health.Source != "acct" {
+		t.Fatalf("unexpected credential health: %+v", rates)
+	}
+	if pro := health.Models["pro"]; !pro.Unhealthy || pro.FailureRatio != 0.75 {
+		t.Fatalf("pro should be unhealthy: %+v", pro)
+	}
+	if flash := health.Models["flash"]; flash.Unhealthy {
+		t.Fatalf("flash has too few samples to be unhealthy: %+v", flash)
+	}
+	if _, ok = health.Models["lite"]; ok {
+		t.Fatal("requests outside the window should not count")
+	}
+	if wide := stats.FailureRates(2 * time.Hour)["a1"]; !wide.Models["lite"].Unhealthy {
+		t.Fatalf("a wider window should include the older failures: %+v", wide)
+	}
+}
+
+func TestFailureRatesIgnoresClientFailures(t *testing.T) {
+	now := time.Now()
+	var records []FlatRecord
+	add := func(failed bool, errorType string) {
+		records = append(records, FlatRecord{APIKey: "k", Model: "m", RequestDetail: RequestDetail{
+			Timestamp: now.Add(-time.Minute), AuthIndex: "a1", Failed: failed, ErrorType: errorType, RequestID: time.Duration(len(records)).String(),
+		}})
+	}
+	add(false, "")
+	add(true, coreusage.ErrorTypeUpstream)
+	add(true, coreusage.ErrorTypeRateLimit)
+	add(true, coreusage.ErrorTypeCanceled)
+	add(true, coreusage.ErrorTypeInvalidRequest)
+	add(true, coreusage.ErrorTypeOther)
+	stats := NewRequestStatistics()
+	stats.MergeSnapshot(SnapshotFromRecords(records))
+
+	if health := stats.FailureRates(0)["a1"]; health.Requests != 3 || health.Failures != 2 {
+		t.Fatalf("credential health = %+v, want 3 requests and 2 failures", health.HealthCounts)
+	}
+}
+
+func TestCredentialUnhealthyRefreshesInBackground(t *testing.T) {
+	SetCredentialHealth(config.CredentialHealth{Enabled: true, Window: "10m", MinRequests: 2, MaxFailureRatio: 0.5})
+	t.Cleanup(func() { SetCredentialHealth(config.CredentialHealth{}) })
+
+	now := time.Now()
+	stats := NewRequestStatistics()
+	stats.MergeSnapshot(SnapshotFromRecords([]FlatRecord{
+		{APIKey: "k", Model: "m", RequestDetail: RequestDetail{Timestamp: now, AuthIndex: "a1", Failed: true, RequestID: "1"}},
+		{APIKey: "k", Model: "m", RequestDetail: RequestDetail{Timestamp: now, AuthIndex: "a1", Failed: true, RequestID: "2"}},
+	}))
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !credentialUnhealthy(stats, "a1", "m") {
+		if time.Now().After(deadline) {
+			t.Fatal("credential was not judged unhealthy after the background refresh")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if credentialUnhealthy(stats, "a1", "other") {
+		t.Fatal("a model the credential has not failed for should stay healthy")
+	}
+}
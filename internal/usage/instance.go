@@ -0,0 +1,68 @@
+package usage
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+)
+
+// instanceIDFile holds the generated instance ID inside the auth directory.
+const instanceIDFile = ".usage-instance-id"
+
+var instanceID atomic.Value
+
+func init() {
+	instanceID.Store("")
+}
+
+// SetInstanceID sets the identifier stamped on requests recorded from now on.
+func SetInstanceID(id string) { instanceID.Store(strings.TrimSpace(id)) }
+
+// InstanceID returns the identifier stamped on newly recorded requests.
+func InstanceID() string {
+	id, _ := instanceID.Load().(string)
+	return id
+}
+
+// ResolveInstanceID returns configured when set. Otherwise it returns the ID stored in dir,
+// generating and storing hostname-<random suffix> on first use so the ID survives restarts.
+func ResolveInstanceID(configured, dir string) (string, error) {
+	if id := strings.TrimSpace(configured); id != "" {
+		return id, nil
+	}
+	path := filepath.Join(dir, instanceIDFile)
+	if data, err := os.ReadFile(path); err == nil {
+		if id := strings.TrimSpace(string(data)); id != "" {
+			return id, nil
+		}
+	} else if !os.IsNotExist(err) {
+		return "", fmt.Errorf("read instance id: %w", err)
+	}
+
+	host, _ := os.Hostname()
+	host = strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '.' {
+			return r
+		}
+		return -1
+	}, host)
+	if host == "" {
+		host = "proxy"
+	}
+	suffix := make([]byte, 3)
+	if _, err := rand.Read(suffix); err != nil {
+		return "", fmt.Errorf("generate instance id: %w", err)
+	}
+	id := host + "-" + hex.EncodeToString(suffix)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", fmt.Errorf("store instance id: %w", err)
+	}
+	if err := os.WriteFile(path, []byte(id+"\n"), 0o600); err != nil {
+		return "", fmt.Errorf("store instance id: %w", err)
+	}
+	return id, nil
+}
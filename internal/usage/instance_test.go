@@ -0,0 +1,39 @@
+package usage
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestResolveInstanceIDPersistsGeneratedID(t *testing.T) {
+	dir := t.TempDir()
+	if id, err := ResolveInstanceID(" eu-1 ", dir); err != nil || id != "eu-1" {
+		t.Fatalf("configured id = %q, %v", id, err)
+	}
+	first, err := ResolveInstanceID("", dir)
+	if err != nil || first == "" || !strings.Contains(first, "-") {
+		t.Fatalf("generated id = %q, %v", first, err)
+	}
+	if again, _ := ResolveInstanceID("", dir); again != first {
+		t.Fatalf("generated id changed across calls: %q then %q", first, again)
+	}
+}
+
+func TestMergeSnapshotKeepsInstancesApart(t *testing.T) {
+	detail := func(instance string) RequestDetail {
+		return RequestDetail{Timestamp: time.Date(2026, 5, 1, 0, 0, 0, 0, time.UTC), Tokens: TokenStats{TotalTokens: 1}, RequestID: "same-id", InstanceID: instance}
+	}
+	snapshot := func(details ...RequestDetail) StatisticsSnapshot {
+		return StatisticsSnapshot{APIs: map[string]APISnapshot{
+			"k": {Models: map[string]ModelSnapshot{"m": {Details: details}}},
+		}}
+	}
+	stats := NewRequestStatistics()
+	if result := stats.MergeSnapshot(snapshot(detail("a"), detail("b"))); result.Added != 2 {
+		t.Fatalf("details from different instances should both be kept: %+v", result)
+	}
+	if result := stats.MergeSnapshot(snapshot(detail("a"))); result.Added != 0 || result.Skipped != 1 {
+		t.Fatalf("re-importing an instance's own records should dedupe: %+v", result)
+	}
+}
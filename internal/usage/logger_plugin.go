@@ -12,7 +12,9 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+	log "github.com/sirupsen/logrus"
 )
 
 var statisticsEnabled atomic.Bool
@@ -34,20 +36,33 @@ type LoggerPlugin struct {
 //   - *LoggerPlugin: A new logger plugin instance wired to the shared statistics store.
 func NewLoggerPlugin() *LoggerPlugin { return &LoggerPlugin{stats: defaultRequestStatistics} }
 
+// AcceptUsage implements coreusage.Gate.
+// Records are accepted only while statistics are enabled; the decision is taken when the
+// record is published so that disabling statistics still drains records already queued.
+func (p *LoggerPlugin) AcceptUsage(ctx context.Context, record coreusage.Record) bool {
+	return statisticsEnabled.Load()
+}
+
 // HandleUsage implements coreusage.Plugin.
-// It updates the in-memory statistics store whenever a usage record is received.
+// It updates the in-memory statistics store whenever an accepted usage record is received.
 //
 // Parameters:
 //   - ctx: The context for the usage record
 //   - record: The usage record to aggregate
 func (p *LoggerPlugin) HandleUsage(ctx context.Context, record coreusage.Record) {
-	if !statisticsEnabled.Load() {
+	if p == nil || p.stats == nil {
 		return
 	}
+	p.stats.record(ctx, record)
+}
+
+// HandleUsageUpdate implements coreusage.UpdateHandler by raising the token counts of the
+// request the update follows.
+func (p *LoggerPlugin) HandleUsageUpdate(ctx context.Context, record coreusage.Record) {
 	if p == nil || p.stats == nil {
 		return
 	}
-	p.stats.Record(ctx, record)
+	p.stats.applyUpdate(ctx, record)
 }
 
 // SetStatisticsEnabled toggles whether in-memory statistics are recorded.
@@ -64,19 +79,49 @@ type RequestStatistics struct {
 	successCount  int64
 	failureCount  int64
 	totalTokens   int64
+	totalCost     int64
 
 	apis map[string]*apiStats
 
+	// system aggregates requests attributed to coreusage.SystemAPIKey by purpose.
+	// They are kept out of the client-facing totals above.
+	system map[string]*apiStats
+
+	// quotas tracks current-day and current-month usage per API key for quota checks.
+	quotas map[string]*quotaCounters
+
 	requestsByDay  map[string]int64
 	requestsByHour map[int]int64
 	tokensByDay    map[string]int64
 	tokensByHour   map[int]int64
+
+	// sink persists client requests as they are recorded; nil keeps them in memory only.
+	sink RecordSink
+
+	// archive holds client requests moved out of the live statistics by Reset, indexed by
+	// request ID in archiveIndex, and resets the latest reset point per API key ("" for all
+	// keys).
+	archive      []FlatRecord
+	archiveIndex map[string]int
+	resets       map[string]time.Time
+}
+
+// SetRecordSink attaches sink to receive every client request recorded from now on.
+// Imported and system requests are not forwarded. A nil sink detaches the current one.
+func (s *RequestStatistics) SetRecordSink(sink RecordSink) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.sink = sink
+	s.mu.Unlock()
 }
 
 // apiStats holds aggregated metrics for a single API key.
 type apiStats struct {
 	TotalRequests int64
 	TotalTokens   int64
+	TotalCost     int64
 	Models        map[string]*modelStats
 }
 
@@ -84,6 +129,7 @@ type apiStats struct {
 type modelStats struct {
 	TotalRequests int64
 	TotalTokens   int64
+	TotalCost     int64
 	Details       []RequestDetail
 }
 
@@ -94,6 +140,28 @@ type RequestDetail struct {
 	AuthIndex string     `json:"auth_index"`
 	Tokens    TokenStats `json:"tokens"`
 	Failed    bool       `json:"failed"`
+	Purpose   string     `json:"purpose,omitempty"`
+	// CostMicrodollars is the estimated cost in millionths of a US dollar, computed with the
+	// pricing in effect when the request was recorded.
+	CostMicrodollars int64 `json:"cost_microdollars,omitempty"`
+	// DurationMS is the upstream latency in milliseconds; zero when the timing is unknown.
+	DurationMS int64 `json:"duration_ms,omitempty"`
+	// RequestID distinguishes otherwise identical requests. Records imported from exports
+	// made before it existed have none and dedupe on their content alone.
+	RequestID string `json:"request_id,omitempty"`
+	// StatusCode and ErrorType describe why a failed request failed; both are empty on success.
+	StatusCode int    `json:"status_code,omitempty"`
+	ErrorType  string `json:"error_type,omitempty"`
+	// ClientIP, UserAgent and Endpoint identify the client that issued the request. They are
+	// empty for internal calls and when collection is disabled, and never enter the dedup key.
+	ClientIP  string `json:"client_ip,omitempty"`
+	UserAgent string `json:"user_agent,omitempty"`
+	Endpoint  string `json:"endpoint,omitempty"`
+	// InstanceID names the proxy instance that recorded the request.
+	InstanceID string `json:"instance_id,omitempty"`
+	// Provider names the executor that served the request (claude, codex, gemini, ...). It
+	// selects how the token counts are priced; records from older exports have none.
+	Provider string `json:"provider,omitempty"`
 }
 
 // TokenStats captures the token usage breakdown for a request.
@@ -112,6 +180,8 @@ type StatisticsSnapshot struct {
 	FailureCount  int64 `json:"failure_count"`
 	TotalTokens   int64 `json:"total_tokens"`
 
+	TotalCostMicrodollars int64 `json:"total_cost_microdollars"`
+
 	APIs map[string]APISnapshot `json:"apis"`
 
 	RequestsByDay  map[string]int64 `json:"requests_by_day"`
@@ -122,16 +192,27 @@ type StatisticsSnapshot struct {
 
 // APISnapshot summarises metrics for a single API key.
 type APISnapshot struct {
-	TotalRequests int64                    `json:"total_requests"`
-	TotalTokens   int64                    `json:"total_tokens"`
-	Models        map[string]ModelSnapshot `json:"models"`
+	TotalRequests         int64                    `json:"total_requests"`
+	TotalTokens           int64                    `json:"total_tokens"`
+	TotalCostMicrodollars int64                    `json:"total_cost_microdollars"`
+	Models                map[string]ModelSnapshot `json:"models"`
 }
 
 // ModelSnapshot summarises metrics for a specific model.
 type ModelSnapshot struct {
-	TotalRequests int64           `json:"total_requests"`
-	TotalTokens   int64           `json:"total_tokens"`
-	Details       []RequestDetail `json:"details"`
+	TotalRequests         int64           `json:"total_requests"`
+	TotalTokens           int64           `json:"total_tokens"`
+	TotalCostMicrodollars int64           `json:"total_cost_microdollars"`
+	Details               []RequestDetail `json:"details"`
+}
+
+// SystemSnapshot summarises requests the proxy issued on its own behalf, grouped by purpose.
+type SystemSnapshot struct {
+	TotalRequests int64 `json:"total_requests"`
+	FailureCount  int64 `json:"failure_count"`
+	TotalTokens   int64 `json:"total_tokens"`
+
+	Purposes map[string]APISnapshot `json:"purposes"`
 }
 
 var defaultRequestStatistics = NewRequestStatistics()
@@ -143,10 +224,14 @@ func GetRequestStatistics() *RequestStatistics { return defaultRequestStatistics
 func NewRequestStatistics() *RequestStatistics {
 	return &RequestStatistics{
 		apis:           make(map[string]*apiStats),
+		system:         make(map[string]*apiStats),
+		quotas:         make(map[string]*quotaCounters),
 		requestsByDay:  make(map[string]int64),
 		requestsByHour: make(map[int]int64),
 		tokensByDay:    make(map[string]int64),
 		tokensByHour:   make(map[int]int64),
+		archiveIndex:   make(map[string]int),
+		resets:         make(map[string]time.Time),
 	}
 }
 
@@ -158,6 +243,14 @@ func (s *RequestStatistics) Record(ctx context.Context, record coreusage.Record)
 	if !statisticsEnabled.Load() {
 		return
 	}
+	s.record(ctx, record)
+}
+
+func (s *RequestStatistics) record(ctx context.Context, record coreusage.Record) {
+	if record.Update {
+		s.applyUpdate(ctx, record)
+		return
+	}
 	timestamp := record.RequestedAt
 	if timestamp.IsZero() {
 		timestamp = time.Now()
@@ -179,10 +272,71 @@ func (s *RequestStatistics) Record(ctx context.Context, record coreusage.Record)
 	}
 	dayKey := timestamp.Format("2006-01-02")
 	hourKey := timestamp.Hour()
+	requestDetail := RequestDetail{
+		Timestamp: timestamp,
+		Source:    record.Source,
+		AuthIndex: record.AuthIndex,
+		Tokens:    detail,
+		Failed:    failed,
+		Purpose:   record.Purpose,
+		Provider:  record.Provider,
+	}
+	if failed {
+		requestDetail.StatusCode = record.StatusCode
+		requestDetail.ErrorType = record.ErrorType
+	}
+	requestDetail.RequestID = record.RequestID
+	if requestDetail.RequestID == "" {
+		requestDetail.RequestID = uuid.NewString()
+	}
+	if record.Latency > 0 {
+		requestDetail.DurationMS = max(record.Latency.Milliseconds(), 1)
+	}
+	requestDetail.CostMicrodollars = EstimateCost(record.Provider, modelName, detail)
+	// System requests run on the proxy's behalf; the client request they happen to share a
+	// context with is not theirs.
+	if record.Purpose == "" {
+		applyClientMetadata(ctx, &requestDetail)
+	}
+	requestDetail.InstanceID = InstanceID()
+
+	if statsKey != coreusage.SystemAPIKey {
+		s.mu.RLock()
+		sink := s.sink
+		s.mu.RUnlock()
+		if sink != nil {
+			if err := sink.Append(FlatRecord{APIKey: statsKey, Model: modelName, RequestDetail: requestDetail}); err != nil {
+				log.Warnf("usage: failed to persist request %s: %v", requestDetail.RequestID, err)
+			}
+		}
+	}
 
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	// A request that started before a reset of its key belongs to the archived period even
+	// when it is recorded after the reset. Quotas follow calendar periods rather than resets,
+	// so it still counts toward them.
+	if statsKey != coreusage.SystemAPIKey && s.archivedAt(statsKey, timestamp) {
+		s.trackQuotaUsage(statsKey, requestDetail)
+		s.archiveRecord(FlatRecord{APIKey: statsKey, Model: modelName, RequestDetail: requestDetail})
+		return
+	}
+
+	if statsKey == coreusage.SystemAPIKey {
+		purpose := record.Purpose
+		if purpose == "" {
+			purpose = "unknown"
+		}
+		stats, ok := s.system[purpose]
+		if !ok {
+			stats = &apiStats{Models: make(map[string]*modelStats)}
+			s.system[purpose] = stats
+		}
+		s.updateAPIStats(stats, modelName, requestDetail)
+		return
+	}
+
 	s.totalRequests++
 	if success {
 		s.successCount++
@@ -190,19 +344,15 @@ func (s *RequestStatistics) Record(ctx context.Context, record coreusage.Record)
 		s.failureCount++
 	}
 	s.totalTokens += totalTokens
+	s.totalCost += requestDetail.CostMicrodollars
 
 	stats, ok := s.apis[statsKey]
 	if !ok {
 		stats = &apiStats{Models: make(map[string]*modelStats)}
 		s.apis[statsKey] = stats
 	}
-	s.updateAPIStats(stats, modelName, RequestDetail{
-		Timestamp: timestamp,
-		Source:    record.Source,
-		AuthIndex: record.AuthIndex,
-		Tokens:    detail,
-		Failed:    failed,
-	})
+	s.updateAPIStats(stats, modelName, requestDetail)
+	s.trackQuotaUsage(statsKey, requestDetail)
 
 	s.requestsByDay[dayKey]++
 	s.requestsByHour[hourKey]++
@@ -213,6 +363,7 @@ func (s *RequestStatistics) Record(ctx context.Context, record coreusage.Record)
 func (s *RequestStatistics) updateAPIStats(stats *apiStats, model string, detail RequestDetail) {
 	stats.TotalRequests++
 	stats.TotalTokens += detail.Tokens.TotalTokens
+	stats.TotalCost += detail.CostMicrodollars
 	modelStatsValue, ok := stats.Models[model]
 	if !ok {
 		modelStatsValue = &modelStats{}
@@ -220,6 +371,7 @@ func (s *RequestStatistics) updateAPIStats(stats *apiStats, model string, detail
 	}
 	modelStatsValue.TotalRequests++
 	modelStatsValue.TotalTokens += detail.Tokens.TotalTokens
+	modelStatsValue.TotalCost += detail.CostMicrodollars
 	modelStatsValue.Details = append(modelStatsValue.Details, detail)
 }
 
@@ -237,24 +389,16 @@ func (s *RequestStatistics) Snapshot() StatisticsSnapshot {
 	result.SuccessCount = s.successCount
 	result.FailureCount = s.failureCount
 	result.TotalTokens = s.totalTokens
+	result.TotalCostMicrodollars = s.totalCost
 
 	result.APIs = make(map[string]APISnapshot, len(s.apis))
 	for apiName, stats := range s.apis {
-		apiSnapshot := APISnapshot{
-			TotalRequests: stats.TotalRequests,
-			TotalTokens:   stats.TotalTokens,
-			Models:        make(map[string]ModelSnapshot, len(stats.Models)),
-		}
-		for modelName, modelStatsValue := range stats.Models {
-			requestDetails := make([]RequestDetail, len(modelStatsValue.Details))
-			copy(requestDetails, modelStatsValue.Details)
-			apiSnapshot.Models[modelName] = ModelSnapshot{
-				TotalRequests: modelStatsValue.TotalRequests,
-				TotalTokens:   modelStatsValue.TotalTokens,
-				Details:       requestDetails,
-			}
+		key := CanonicalAPIKey(apiName)
+		if existing, ok := result.APIs[key]; ok {
+			result.APIs[key] = mergeAPISnapshot(existing, snapshotAPIStats(stats))
+			continue
 		}
-		result.APIs[apiName] = apiSnapshot
+		result.APIs[key] = snapshotAPIStats(stats)
 	}
 
 	result.RequestsByDay = make(map[string]int64, len(s.requestsByDay))
@@ -282,13 +426,63 @@ func (s *RequestStatistics) Snapshot() StatisticsSnapshot {
 	return result
 }
 
+// SystemSnapshot returns a copy of the metrics recorded for system-initiated requests.
+func (s *RequestStatistics) SystemSnapshot() SystemSnapshot {
+	result := SystemSnapshot{Purposes: make(map[string]APISnapshot)}
+	if s == nil {
+		return result
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for purpose, stats := range s.system {
+		snapshot := snapshotAPIStats(stats)
+		result.TotalRequests += snapshot.TotalRequests
+		result.TotalTokens += snapshot.TotalTokens
+		for _, modelSnapshot := range snapshot.Models {
+			for _, detail := range modelSnapshot.Details {
+				if detail.Failed {
+					result.FailureCount++
+				}
+			}
+		}
+		result.Purposes[purpose] = snapshot
+	}
+	return result
+}
+
+func snapshotAPIStats(stats *apiStats) APISnapshot {
+	apiSnapshot := APISnapshot{
+		TotalRequests:         stats.TotalRequests,
+		TotalTokens:           stats.TotalTokens,
+		TotalCostMicrodollars: stats.TotalCost,
+		Models:                make(map[string]ModelSnapshot, len(stats.Models)),
+	}
+	for modelName, modelStatsValue := range stats.Models {
+		requestDetails := make([]RequestDetail, len(modelStatsValue.Details))
+		copy(requestDetails, modelStatsValue.Details)
+		apiSnapshot.Models[modelName] = ModelSnapshot{
+			TotalRequests:         modelStatsValue.TotalRequests,
+			TotalTokens:           modelStatsValue.TotalTokens,
+			TotalCostMicrodollars: modelStatsValue.TotalCost,
+			Details:               requestDetails,
+		}
+	}
+	return apiSnapshot
+}
+
 type MergeResult struct {
 	Added   int64 `json:"added"`
 	Skipped int64 `json:"skipped"`
 }
 
 // MergeSnapshot merges an exported statistics snapshot into the current store.
-// Existing data is preserved and duplicate request details are skipped.
+// Existing data is preserved and duplicate request details are skipped. Details carrying a
+// request ID are duplicates only of a detail with the same ID and content; details without
+// one (older exports) are duplicates of any detail with the same content, and a detail with
+// an ID matching the content of an ID-less one already held replaces it as a duplicate.
+// Content is compared at second precision because older exports wrote RFC3339 timestamps.
 func (s *RequestStatistics) MergeSnapshot(snapshot StatisticsSnapshot) MergeResult {
 	result := MergeResult{}
 	if s == nil {
@@ -296,9 +490,24 @@ func (s *RequestStatistics) MergeSnapshot(snapshot StatisticsSnapshot) MergeResu
 	}
 
 	s.mu.Lock()
-	defer s.mu.Unlock()
+	sink := s.sink
+	var added []FlatRecord
 
 	seen := make(map[string]struct{})
+	seenContent := make(map[string]struct{})
+	seenSecond := make(map[string]struct{})
+	// legacyContent counts the ID-less details per content key, each of which absorbs one
+	// incoming detail that carries an ID. Whole-second keys match any sub-second timestamp.
+	legacyContent := make(map[string]int)
+	markSeen := func(apiName, modelName string, detail RequestDetail) {
+		seen[dedupKey(apiName, modelName, detail)] = struct{}{}
+		content := contentDedupKey(apiName, modelName, detail)
+		seenContent[content] = struct{}{}
+		seenSecond[secondDedupKey(apiName, modelName, detail)] = struct{}{}
+		if detail.RequestID == "" {
+			legacyContent[content]++
+		}
+	}
 	for apiName, stats := range s.apis {
 		if stats == nil {
 			continue
@@ -308,23 +517,19 @@ func (s *RequestStatistics) MergeSnapshot(snapshot StatisticsSnapshot) MergeResu
 				continue
 			}
 			for _, detail := range modelStatsValue.Details {
-				seen[dedupKey(apiName, modelName, detail)] = struct{}{}
+				markSeen(apiName, modelName, detail)
 			}
 		}
 	}
+	for _, record := range s.archive {
+		markSeen(record.APIKey, record.Model, record.RequestDetail)
+	}
 
 	for apiName, apiSnapshot := range snapshot.APIs {
 		apiName = strings.TrimSpace(apiName)
 		if apiName == "" {
 			continue
 		}
-		stats, ok := s.apis[apiName]
-		if !ok || stats == nil {
-			stats = &apiStats{Models: make(map[string]*modelStats)}
-			s.apis[apiName] = stats
-		} else if stats.Models == nil {
-			stats.Models = make(map[string]*modelStats)
-		}
 		for modelName, modelSnapshot := range apiSnapshot.Models {
 			modelName = strings.TrimSpace(modelName)
 			if modelName == "" {
@@ -335,22 +540,63 @@ func (s *RequestStatistics) MergeSnapshot(snapshot StatisticsSnapshot) MergeResu
 				if detail.Timestamp.IsZero() {
 					detail.Timestamp = time.Now()
 				}
+				duplicates := seen
 				key := dedupKey(apiName, modelName, detail)
-				if _, exists := seen[key]; exists {
+				if detail.RequestID == "" {
+					duplicates = seenContent
+					key = contentDedupKey(apiName, modelName, detail)
+					// Older exports wrote RFC3339 timestamps; one without a sub-second part
+					// matches any detail recorded within the same second.
+					if detail.Timestamp.Nanosecond() == 0 {
+						duplicates = seenSecond
+					}
+				}
+				if _, exists := duplicates[key]; exists {
 					result.Skipped++
 					continue
 				}
-				seen[key] = struct{}{}
-				s.recordImported(apiName, modelName, stats, detail)
+				if detail.RequestID != "" {
+					if legacy := legacyMatch(legacyContent, apiName, modelName, detail); legacy != "" {
+						legacyContent[legacy]--
+						result.Skipped++
+						continue
+					}
+				}
+				markSeen(apiName, modelName, detail)
+				if s.archivedAt(apiName, detail.Timestamp) {
+					// Requests from before a reset of their key stay archived when imported.
+					s.trackQuotaUsage(apiName, detail)
+					s.archiveRecord(FlatRecord{APIKey: apiName, Model: modelName, RequestDetail: detail})
+				} else {
+					s.recordImported(apiName, modelName, detail)
+				}
 				result.Added++
+				if sink != nil {
+					added = append(added, FlatRecord{APIKey: apiName, Model: modelName, RequestDetail: detail})
+				}
 			}
 		}
 	}
+	s.mu.Unlock()
 
+	// Imported requests are persisted like recorded ones so they survive a restart.
+	for _, record := range added {
+		if err := sink.Append(record); err != nil {
+			log.Warnf("usage: failed to persist imported request: %v", err)
+			break
+		}
+	}
 	return result
 }
 
-func (s *RequestStatistics) recordImported(apiName, modelName string, stats *apiStats, detail RequestDetail) {
+func (s *RequestStatistics) recordImported(apiName, modelName string, detail RequestDetail) {
+	stats, ok := s.apis[apiName]
+	if !ok || stats == nil {
+		stats = &apiStats{Models: make(map[string]*modelStats)}
+		s.apis[apiName] = stats
+	} else if stats.Models == nil {
+		stats.Models = make(map[string]*modelStats)
+	}
 	totalTokens := detail.Tokens.TotalTokens
 	if totalTokens < 0 {
 		totalTokens = 0
@@ -363,8 +609,10 @@ func (s *RequestStatistics) recordImported(apiName, modelName string, stats *api
 		s.successCount++
 	}
 	s.totalTokens += totalTokens
+	s.totalCost += detail.CostMicrodollars
 
 	s.updateAPIStats(stats, modelName, detail)
+	s.trackQuotaUsage(apiName, detail)
 
 	dayKey := detail.Timestamp.Format("2006-01-02")
 	hourKey := detail.Timestamp.Hour()
@@ -375,12 +623,43 @@ func (s *RequestStatistics) recordImported(apiName, modelName string, stats *api
 	s.tokensByHour[hourKey] += totalTokens
 }
 
+// dedupKey identifies a request detail by its content and request ID.
 func dedupKey(apiName, modelName string, detail RequestDetail) string {
-	timestamp := detail.Timestamp.UTC().Format(time.RFC3339Nano)
+	return contentDedupKey(apiName, modelName, detail) + "|" + detail.RequestID + "|" + detail.InstanceID
+}
+
+// contentDedupKey identifies a request detail by its content alone, as exports made before
+// request IDs were recorded did.
+func contentDedupKey(apiName, modelName string, detail RequestDetail) string {
+	return contentKeyAt(apiName, modelName, detail, detail.Timestamp.UTC().Format(time.RFC3339Nano))
+}
+
+// secondDedupKey is contentDedupKey with the timestamp truncated to whole seconds. For a
+// timestamp without a sub-second part both keys are equal.
+func secondDedupKey(apiName, modelName string, detail RequestDetail) string {
+	return contentKeyAt(apiName, modelName, detail, detail.Timestamp.UTC().Truncate(time.Second).Format(time.RFC3339Nano))
+}
+
+// legacyMatch returns the key of an ID-less detail in legacy that absorbs detail: one with
+// the same content at full precision, or written with a whole-second timestamp within the
+// same second. It returns "" when there is none.
+func legacyMatch(legacy map[string]int, apiName, modelName string, detail RequestDetail) string {
+	if key := contentDedupKey(apiName, modelName, detail); legacy[key] > 0 {
+		return key
+	}
+	if key := secondDedupKey(apiName, modelName, detail); legacy[key] > 0 {
+		return key
+	}
+	return ""
+}
+
+// contentKeyAt builds a content key with the given timestamp. Aliased keys are keyed as the
+// key they alias, so an export taken before MergeAliasedKeys still matches the merged details.
+func contentKeyAt(apiName, modelName string, detail RequestDetail, timestamp string) string {
 	tokens := normaliseTokenStats(detail.Tokens)
 	return fmt.Sprintf(
 		"%s|%s|%s|%s|%s|%t|%d|%d|%d|%d|%d",
-		apiName,
+		CanonicalAPIKey(apiName),
 		modelName,
 		timestamp,
 		detail.Source,
@@ -436,6 +715,9 @@ func resolveSuccess(ctx context.Context) bool {
 
 const httpStatusBadRequest = 400
 
+// NormaliseDetail converts a provider usage detail into the TokenStats recorded for it.
+func NormaliseDetail(detail coreusage.Detail) TokenStats { return normaliseDetail(detail) }
+
 func normaliseDetail(detail coreusage.Detail) TokenStats {
 	tokens := TokenStats{
 		InputTokens:     detail.InputTokens,
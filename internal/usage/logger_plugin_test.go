@@ -0,0 +1,211 @@
+package usage
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+)
+
+func TestRecordSeparatesSystemUsage(t *testing.T) {
+	stats := NewRequestStatistics()
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	stats.Record(context.Background(), coreusage.Record{
+		APIKey:      "client-key",
+		Model:       "gemini-2.5-pro",
+		RequestedAt: now,
+		Detail:      coreusage.Detail{InputTokens: 10, OutputTokens: 5},
+	})
+	stats.Record(context.Background(), coreusage.Record{
+		APIKey:      coreusage.SystemAPIKey,
+		Purpose:     coreusage.PurposeTokenRefresh,
+		Provider:    "claude",
+		RequestedAt: now,
+		Failed:      true,
+	})
+	stats.Record(context.Background(), coreusage.Record{
+		APIKey:      coreusage.SystemAPIKey,
+		Purpose:     coreusage.PurposeModelRefresh,
+		Provider:    "antigravity",
+		RequestedAt: now,
+	})
+
+	snapshot := stats.Snapshot()
+	if snapshot.TotalRequests != 1 || snapshot.TotalTokens != 15 {
+		t.Fatalf("client totals = %d requests / %d tokens, want 1 / 15", snapshot.TotalRequests, snapshot.TotalTokens)
+	}
+	if _, ok := snapshot.APIs[coreusage.SystemAPIKey]; ok {
+		t.Fatalf("system usage leaked into client snapshot")
+	}
+	if snapshot.RequestsByDay["2026-01-02"] != 1 {
+		t.Fatalf("requests_by_day = %d, want 1", snapshot.RequestsByDay["2026-01-02"])
+	}
+
+	system := stats.SystemSnapshot()
+	if system.TotalRequests != 2 || system.FailureCount != 1 {
+		t.Fatalf("system totals = %d requests / %d failures, want 2 / 1", system.TotalRequests, system.FailureCount)
+	}
+	refresh, ok := system.Purposes[coreusage.PurposeTokenRefresh]
+	if !ok || refresh.TotalRequests != 1 {
+		t.Fatalf("token_refresh purpose missing: %+v", system.Purposes)
+	}
+	details := refresh.Models["unknown"].Details
+	if len(details) != 1 || details[0].Purpose != coreusage.PurposeTokenRefresh || !details[0].Failed {
+		t.Fatalf("unexpected token_refresh details: %+v", details)
+	}
+}
+
+func TestMergeSnapshotDedupesByRequestID(t *testing.T) {
+	ts := time.Date(2026, 2, 3, 4, 5, 6, 0, time.UTC)
+	detail := func(requestID string) RequestDetail {
+		return RequestDetail{Timestamp: ts, Source: "s", Tokens: TokenStats{InputTokens: 7, TotalTokens: 7}, RequestID: requestID}
+	}
+	snapshot := func(details ...RequestDetail) StatisticsSnapshot {
+		return StatisticsSnapshot{APIs: map[string]APISnapshot{
+			"k": {Models: map[string]ModelSnapshot{"m": {Details: details}}},
+		}}
+	}
+
+	stats := NewRequestStatistics()
+	if result := stats.MergeSnapshot(snapshot(detail("a"), detail("b"))); result.Added != 2 || result.Skipped != 0 {
+		t.Fatalf("identical details with different request IDs should both be kept: %+v", result)
+	}
+	if result := stats.MergeSnapshot(snapshot(detail("a"), detail("b"))); result.Added != 0 || result.Skipped != 2 {
+		t.Fatalf("replayed snapshot should dedupe: %+v", result)
+	}
+	if result := stats.MergeSnapshot(snapshot(detail(""))); result.Added != 0 || result.Skipped != 1 {
+		t.Fatalf("legacy detail without a request ID should match existing content: %+v", result)
+	}
+	if got := stats.Snapshot().TotalRequests; got != 2 {
+		t.Fatalf("total requests = %d, want 2", got)
+	}
+}
+
+func TestMergeSnapshotMatchesLegacyTimestamps(t *testing.T) {
+	live := RequestDetail{
+		Timestamp: time.Date(2026, 2, 3, 4, 5, 6, 789000000, time.UTC),
+		Tokens:    TokenStats{InputTokens: 3, TotalTokens: 3},
+		RequestID: "live",
+	}
+	// Older exports wrote RFC3339 timestamps and no request ID.
+	var legacy RequestDetail
+	if err := json.Unmarshal([]byte(`{"timestamp":"2026-02-03T04:05:06Z","tokens":{"input_tokens":3,"total_tokens":3}}`), &legacy); err != nil {
+		t.Fatalf("decode legacy detail: %v", err)
+	}
+	snapshot := func(detail RequestDetail) StatisticsSnapshot {
+		return StatisticsSnapshot{APIs: map[string]APISnapshot{
+			"k": {Models: map[string]ModelSnapshot{"m": {Details: []RequestDetail{detail}}}},
+		}}
+	}
+
+	for name, order := range map[string][2]RequestDetail{
+		"legacy first": {legacy, live},
+		"live first":   {live, legacy},
+	} {
+		stats := NewRequestStatistics()
+		stats.MergeSnapshot(snapshot(order[0]))
+		if result := stats.MergeSnapshot(snapshot(order[1])); result.Added != 0 || result.Skipped != 1 {
+			t.Fatalf("%s: the second format should dedupe against the first: %+v", name, result)
+		}
+		if got := stats.Snapshot().TotalRequests; got != 1 {
+			t.Fatalf("%s: total requests = %d, want 1", name, got)
+		}
+	}
+}
+
+func TestMergeSnapshotKeepsSubSecondLegacyDetailsApart(t *testing.T) {
+	// Exports made before request IDs carry RFC3339Nano timestamps; two zero-token failures
+	// within one second are different requests.
+	details := []RequestDetail{
+		{Timestamp: time.Date(2026, 2, 3, 4, 5, 6, 100000000, time.UTC), Failed: true},
+		{Timestamp: time.Date(2026, 2, 3, 4, 5, 6, 200000000, time.UTC), Failed: true},
+	}
+	snapshot := StatisticsSnapshot{APIs: map[string]APISnapshot{
+		"k": {Models: map[string]ModelSnapshot{"m": {Details: details}}},
+	}}
+	stats := NewRequestStatistics()
+	if result := stats.MergeSnapshot(snapshot); result.Added != 2 || result.Skipped != 0 {
+		t.Fatalf("both legacy details should import: %+v", result)
+	}
+	if result := stats.MergeSnapshot(snapshot); result.Added != 0 || result.Skipped != 2 {
+		t.Fatalf("re-importing should skip both: %+v", result)
+	}
+}
+
+func TestRecordAssignsRequestIDs(t *testing.T) {
+	stats := NewRequestStatistics()
+	record := coreusage.Record{
+		APIKey:      "k",
+		Model:       "m",
+		RequestedAt: time.Date(2026, 2, 3, 4, 5, 6, 0, time.UTC),
+		Detail:      coreusage.Detail{InputTokens: 1},
+	}
+	stats.Record(context.Background(), record)
+	stats.Record(context.Background(), record)
+
+	details := stats.Snapshot().APIs["k"].Models["m"].Details
+	if len(details) != 2 || details[0].RequestID == "" || details[0].RequestID == details[1].RequestID {
+		t.Fatalf("identical records should get distinct request IDs: %+v", details)
+	}
+	if result := NewRequestStatistics().MergeSnapshot(stats.Snapshot()); result.Added != 2 {
+		t.Fatalf("exported identical records should both import: %+v", result)
+	}
+}
+
+func TestRecordCapturesClientMetadata(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	t.Cleanup(func() { _ = SetClientMetadataMode("") })
+
+	var ginCtx *gin.Context
+	engine := gin.New()
+	engine.POST("/v1/chat/completions", func(c *gin.Context) { ginCtx = c })
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	req.RemoteAddr = "203.0.113.77:5000"
+	req.Header.Set("User-Agent", "test-agent/1.0")
+	engine.ServeHTTP(httptest.NewRecorder(), req)
+	ctx := context.WithValue(context.Background(), "gin", ginCtx)
+
+	record := func(mode string) RequestDetail {
+		if err := SetClientMetadataMode(mode); err != nil {
+			t.Fatalf("set mode %q: %v", mode, err)
+		}
+		stats := NewRequestStatistics()
+		stats.Record(ctx, coreusage.Record{APIKey: "k", Model: "m", RequestedAt: time.Now()})
+		return stats.Snapshot().APIs["k"].Models["m"].Details[0]
+	}
+
+	full := record("full")
+	if full.ClientIP != "203.0.113.77" || full.UserAgent != "test-agent/1.0" || full.Endpoint != "POST /v1/chat/completions" {
+		t.Fatalf("unexpected client metadata: %+v", full)
+	}
+	if got := record("truncated-ip").ClientIP; got != "203.0.113.0" {
+		t.Fatalf("truncated ip = %q", got)
+	}
+	if got := record("hashed-ip").ClientIP; got != "hmac:"+keyedDigest("203.0.113.77", 8) {
+		t.Fatalf("hashed ip = %q", got)
+	}
+	stats := NewRequestStatistics()
+	stats.Record(ctx, coreusage.Record{APIKey: coreusage.SystemAPIKey, Model: "m", Purpose: coreusage.PurposeTokenRefresh, RequestedAt: time.Now()})
+	if system := stats.SystemSnapshot().Purposes[coreusage.PurposeTokenRefresh].Models["m"].Details[0]; system.ClientIP != "" || system.Endpoint != "" {
+		t.Fatalf("system request picked up client metadata: %+v", system)
+	}
+	if off := record("off"); off.ClientIP != "" || off.UserAgent != "" || off.Endpoint != "" {
+		t.Fatalf("metadata recorded while off: %+v", off)
+	}
+	if err := SetClientMetadataMode("bogus"); err == nil {
+		t.Fatal("expected an error for an unknown mode")
+	}
+
+	// Client metadata must not affect deduplication.
+	withMeta := full
+	withMeta.ClientIP = "198.51.100.1"
+	if dedupKey("k", "m", full) != dedupKey("k", "m", withMeta) {
+		t.Fatal("client metadata changed the dedup key")
+	}
+}
@@ -0,0 +1,168 @@
+package usage
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	log "github.com/sirupsen/logrus"
+)
+
+// Persistence forwards recorded requests to the active file store. Persistence can be paused
+// and the store moved to another directory at runtime; records arriving during a switch wait
+// for it to finish instead of being dropped or written to a closed file.
+type Persistence struct {
+	mu      sync.Mutex
+	cfg     config.UsageStore
+	store   *FileStore
+	enabled bool
+}
+
+// PersistenceStatus reports the active usage store.
+type PersistenceStatus struct {
+	Backend string `json:"backend"`
+	Dir     string `json:"dir"`
+	Enabled bool   `json:"enabled"`
+}
+
+var currentPersistence atomic.Pointer[Persistence]
+
+// CurrentPersistence returns the persistence opened by OpenUsageStore, or nil when usage is
+// kept in memory only.
+func CurrentPersistence() *Persistence { return currentPersistence.Load() }
+
+// OpenUsageStore opens the backend selected by cfg, merges its records into stats and
+// attaches it so newly recorded requests are persisted. It returns nil for the memory backend.
+func OpenUsageStore(cfg config.UsageStore, stats *RequestStatistics) (*Persistence, error) {
+	switch strings.ToLower(strings.TrimSpace(cfg.Backend)) {
+	case "", "memory":
+		return nil, nil
+	case "jsonl":
+	default:
+		return nil, fmt.Errorf("usage store: unknown backend %q", cfg.Backend)
+	}
+	store, err := NewFileStore(cfg)
+	if err != nil {
+		return nil, err
+	}
+	records, resets, err := store.load(time.Time{}, time.Time{})
+	if err != nil {
+		_ = store.Close()
+		return nil, err
+	}
+	result := stats.MergeSnapshot(SnapshotFromRecords(records))
+	for _, marker := range resets {
+		stats.Reset(marker)
+	}
+	log.Infof("usage store: loaded %d records and %d resets from %s (%d duplicates skipped)", result.Added, len(resets), store.dir, result.Skipped)
+
+	p := &Persistence{cfg: cfg, store: store, enabled: true}
+	stats.SetRecordSink(p)
+	currentPersistence.Store(p)
+	return p, nil
+}
+
+// Append implements RecordSink.
+func (p *Persistence) Append(record FlatRecord) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.enabled || p.store == nil {
+		return nil
+	}
+	return p.store.Append(record)
+}
+
+// AppendReset persists a reset marker. Markers are written even while persistence is
+// paused, since losing one would bring the archived requests back as live on restart.
+func (p *Persistence) AppendReset(marker ResetMarker) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.store == nil {
+		return errors.New("usage store: closed")
+	}
+	return p.store.AppendReset(marker)
+}
+
+// Status reports the active directory and whether records are being persisted.
+func (p *Persistence) Status() PersistenceStatus {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return PersistenceStatus{Backend: "jsonl", Dir: p.cfg.Dir, Enabled: p.enabled}
+}
+
+// SetEnabled pauses or resumes persistence. Requests recorded while paused stay in memory
+// but are never written to the store.
+func (p *Persistence) SetEnabled(enabled bool) {
+	p.mu.Lock()
+	p.enabled = enabled
+	p.mu.Unlock()
+}
+
+// Switch moves persistence to dir. When copyExisting is true the records of the current
+// store are appended to the new one first; it returns how many were copied. On error the
+// current store stays active.
+func (p *Persistence) Switch(dir string, copyExisting bool) (int, error) {
+	dir = strings.TrimSpace(dir)
+	if dir == "" {
+		return 0, errors.New("usage store: dir is required")
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.store == nil {
+		return 0, errors.New("usage store: closed")
+	}
+	if filepath.Clean(dir) == filepath.Clean(p.cfg.Dir) {
+		return 0, nil
+	}
+
+	cfg := p.cfg
+	cfg.Dir = dir
+	next, err := NewFileStore(cfg)
+	if err != nil {
+		return 0, err
+	}
+	copied := 0
+	if copyExisting {
+		records, resets, errLoad := p.store.load(time.Time{}, time.Time{})
+		if errLoad != nil {
+			_ = next.Close()
+			return 0, errLoad
+		}
+		for _, record := range records {
+			if err = next.Append(record); err != nil {
+				_ = next.Close()
+				return 0, fmt.Errorf("usage store: copy: %w", err)
+			}
+			copied++
+		}
+		for _, marker := range resets {
+			if err = next.AppendReset(marker); err != nil {
+				_ = next.Close()
+				return 0, fmt.Errorf("usage store: copy: %w", err)
+			}
+		}
+	}
+	if err = p.store.Close(); err != nil {
+		log.Warnf("usage store: close %s: %v", p.cfg.Dir, err)
+	}
+	p.store = next
+	p.cfg = cfg
+	return copied, nil
+}
+
+// Close closes the active store. Later records are not persisted.
+func (p *Persistence) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.store == nil {
+		return nil
+	}
+	err := p.store.Close()
+	p.store = nil
+	return err
+}
@@ -0,0 +1,58 @@
+package usage
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+)
+
+func TestPersistencePauseAndSwitch(t *testing.T) {
+	oldDir, newDir := t.TempDir(), t.TempDir()
+	stats := NewRequestStatistics()
+	persistence, err := OpenUsageStore(config.UsageStore{Backend: "jsonl", Dir: oldDir}, stats)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer func() { _ = persistence.Close() }()
+	record := func(n int) {
+		for i := 0; i < n; i++ {
+			stats.Record(context.Background(), coreusage.Record{APIKey: "k", Model: "m", RequestedAt: time.Now()})
+		}
+	}
+
+	record(2)
+	persistence.SetEnabled(false)
+	record(1)
+	persistence.SetEnabled(true)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		record(20)
+	}()
+	copied, err := persistence.Switch(newDir, true)
+	wg.Wait()
+	if err != nil {
+		t.Fatalf("switch: %v", err)
+	}
+	if status := persistence.Status(); status.Dir != newDir || !status.Enabled {
+		t.Fatalf("unexpected status: %+v", status)
+	}
+
+	oldRecords, _ := (&FileStore{dir: oldDir}).LoadAll()
+	newRecords, _ := persistence.store.LoadAll()
+	// Every persisted record is either copied or written after the switch; the paused one is in neither.
+	if len(oldRecords)+len(newRecords)-copied != 22 || copied != len(oldRecords) {
+		t.Fatalf("old=%d new=%d copied=%d, want 22 persisted records", len(oldRecords), len(newRecords), copied)
+	}
+	reloaded := NewRequestStatistics()
+	reloaded.MergeSnapshot(SnapshotFromRecords(newRecords))
+	if got := reloaded.Snapshot().TotalRequests; got != 22 {
+		t.Fatalf("new store holds %d requests, want 22", got)
+	}
+}
@@ -0,0 +1,143 @@
+package usage
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"sync/atomic"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+// pricingTable resolves model prices; exact matches win over the longest matching prefix.
+type pricingTable struct {
+	exact    map[string]config.ModelPrice
+	prefixes []config.ModelPrice
+}
+
+var currentPricing atomic.Pointer[pricingTable]
+
+// SetPricing replaces the price list used to estimate the cost of newly recorded requests.
+// Requests recorded earlier keep the cost computed when they were recorded.
+func SetPricing(prices []config.ModelPrice) {
+	table := &pricingTable{exact: make(map[string]config.ModelPrice)}
+	for _, price := range prices {
+		model := strings.ToLower(strings.TrimSpace(price.Model))
+		if model == "" {
+			continue
+		}
+		if prefix, ok := strings.CutSuffix(model, "*"); ok {
+			price.Model = prefix
+			table.prefixes = append(table.prefixes, price)
+			continue
+		}
+		table.exact[model] = price
+	}
+	sort.SliceStable(table.prefixes, func(i, j int) bool {
+		return len(table.prefixes[i].Model) > len(table.prefixes[j].Model)
+	})
+	currentPricing.Store(table)
+}
+
+func (t *pricingTable) lookup(model string) (config.ModelPrice, bool) {
+	if t == nil {
+		return config.ModelPrice{}, false
+	}
+	model = strings.ToLower(strings.TrimSpace(model))
+	if price, ok := t.exact[model]; ok {
+		return price, true
+	}
+	for _, price := range t.prefixes {
+		if strings.HasPrefix(model, price.Model) {
+			return price, true
+		}
+	}
+	return config.ModelPrice{}, false
+}
+
+// EstimateCost returns the cost of tokens for model in microdollars using the current
+// pricing, or zero when the model has no price. provider selects how the counts are read
+// (see billableTokens). Cached tokens are billed at the cached price when one is configured
+// and at the input price otherwise.
+func EstimateCost(provider, model string, tokens TokenStats) int64 {
+	price, ok := currentPricing.Load().lookup(model)
+	if !ok {
+		return 0
+	}
+	input, cached, output := billableTokens(provider, tokens)
+	cachedPrice := price.CachedPerMillion
+	if cachedPrice <= 0 {
+		cachedPrice = price.InputPerMillion
+	}
+	cost := float64(input)*price.InputPerMillion + float64(cached)*cachedPrice + float64(output)*price.OutputPerMillion
+	// Prices are per million tokens, so tokens * price is already in microdollars.
+	return int64(math.Round(cost))
+}
+
+// billableTokens splits tokens into uncached input, cached input and output. Providers
+// report them differently: OpenAI-style APIs (codex, OpenAI compatible, qwen, iflow,
+// copilot) count cached tokens within the input and reasoning within the output, Claude
+// reports cache reads next to the input, and the Gemini family reports thinking tokens
+// next to the output.
+func billableTokens(provider string, tokens TokenStats) (input, cached, output int64) {
+	cachedSeparate, reasoningSeparate := tokenConventions(provider, tokens)
+	input, cached, output = tokens.InputTokens, tokens.CachedTokens, tokens.OutputTokens
+	if !cachedSeparate {
+		cached = min(cached, input)
+		input -= cached
+	}
+	if reasoningSeparate {
+		output += tokens.ReasoningTokens
+	}
+	return input, cached, output
+}
+
+func tokenConventions(provider string, tokens TokenStats) (cachedSeparate, reasoningSeparate bool) {
+	switch strings.ToLower(strings.TrimSpace(provider)) {
+	case "claude":
+		return true, false
+	case "gemini", "gemini-cli", "aistudio", "vertex", "antigravity":
+		return false, true
+	case "":
+		// Records from older exports carry no provider; their counts tell the conventions
+		// apart. Cache reads exceeding the input cannot be part of it, and reasoning is
+		// separate when the total counts it on top of the output.
+		return tokens.CachedTokens > tokens.InputTokens,
+			tokens.ReasoningTokens > 0 && tokens.TotalTokens >= tokens.InputTokens+tokens.OutputTokens+tokens.ReasoningTokens
+	}
+	return false, false
+}
+
+// RecomputeCosts re-estimates the cost of recorded requests with the current pricing.
+// When onlyMissing is true only requests recorded without a cost are updated. It returns
+// the number of requests whose cost changed.
+func (s *RequestStatistics) RecomputeCosts(onlyMissing bool) int64 {
+	if s == nil {
+		return 0
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var changed int64
+	for _, stats := range s.apis {
+		for modelName, modelStatsValue := range stats.Models {
+			for i := range modelStatsValue.Details {
+				detail := &modelStatsValue.Details[i]
+				if onlyMissing && detail.CostMicrodollars != 0 {
+					continue
+				}
+				cost := EstimateCost(detail.Provider, modelName, detail.Tokens)
+				delta := cost - detail.CostMicrodollars
+				if delta == 0 {
+					continue
+				}
+				detail.CostMicrodollars = cost
+				modelStatsValue.TotalCost += delta
+				stats.TotalCost += delta
+				s.totalCost += delta
+				changed++
+			}
+		}
+	}
+	return changed
+}
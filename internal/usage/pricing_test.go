@@ -0,0 +1,96 @@
+package usage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+)
+
+func TestEstimateCostLookup(t *testing.T) {
+	SetPricing([]config.ModelPrice{
+		{Model: "gpt-5*", InputPerMillion: 1, OutputPerMillion: 10},
+		{Model: "gpt-5-mini*", InputPerMillion: 0.25, OutputPerMillion: 2},
+		{Model: "gpt-5-mini-exact", InputPerMillion: 5, OutputPerMillion: 5, CachedPerMillion: 0.5},
+	})
+	t.Cleanup(func() { SetPricing(nil) })
+
+	tokens := TokenStats{InputTokens: 1000, OutputTokens: 100, ReasoningTokens: 100, CachedTokens: 400}
+	cases := []struct {
+		model string
+		want  int64
+	}{
+		{"gpt-5-codex", 1000 + 200*10},
+		{"gpt-5-mini-2025", 250 + 200*2},
+		{"GPT-5-MINI-EXACT", 600*5 + 400*0.5 + 200*5},
+		{"claude-sonnet", 0},
+	}
+	for _, tc := range cases {
+		if got := EstimateCost("gemini", tc.model, tokens); got != tc.want {
+			t.Fatalf("EstimateCost(%q) = %d, want %d", tc.model, got, tc.want)
+		}
+	}
+}
+
+func TestEstimateCostWithoutProvider(t *testing.T) {
+	SetPricing([]config.ModelPrice{{Model: "m", InputPerMillion: 1, CachedPerMillion: 0.1, OutputPerMillion: 10}})
+	t.Cleanup(func() { SetPricing(nil) })
+
+	cases := []struct {
+		name   string
+		tokens TokenStats
+		want   int64
+	}{
+		{"cache reads beside the input", TokenStats{InputTokens: 25, CachedTokens: 4096, OutputTokens: 312, TotalTokens: 337}, 25 + 410 + 3120},
+		{"reasoning within the output", TokenStats{InputTokens: 75, OutputTokens: 1186, ReasoningTokens: 1024, TotalTokens: 1261}, 75 + 11860},
+		{"reasoning beside the output", TokenStats{InputTokens: 12, OutputTokens: 48, ReasoningTokens: 100, TotalTokens: 160}, 12 + 1480},
+	}
+	for _, tc := range cases {
+		if got := EstimateCost("", "m", tc.tokens); got != tc.want {
+			t.Fatalf("%s: cost = %d, want %d", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestPricingChangesApplyToNewRequestsOnly(t *testing.T) {
+	SetPricing([]config.ModelPrice{{Model: "m", InputPerMillion: 2}})
+	t.Cleanup(func() { SetPricing(nil) })
+
+	stats := NewRequestStatistics()
+	record := func(model string) {
+		stats.Record(context.Background(), coreusage.Record{
+			APIKey:      "k",
+			Model:       model,
+			RequestedAt: time.Now(),
+			Detail:      coreusage.Detail{InputTokens: 100},
+		})
+	}
+	record("m")
+	record("unpriced")
+	SetPricing([]config.ModelPrice{{Model: "m", InputPerMillion: 3}, {Model: "unpriced", InputPerMillion: 1}})
+	record("m")
+
+	snapshot := stats.Snapshot()
+	if snapshot.TotalCostMicrodollars != 200+300 {
+		t.Fatalf("total cost = %d, want 500", snapshot.TotalCostMicrodollars)
+	}
+	if unpriced := snapshot.APIs["k"].Models["unpriced"]; unpriced.TotalTokens != 100 || unpriced.TotalCostMicrodollars != 0 {
+		t.Fatalf("unpriced model should count tokens without cost: %+v", unpriced)
+	}
+
+	if updated := stats.RecomputeCosts(true); updated != 1 {
+		t.Fatalf("recompute missing updated %d requests, want 1", updated)
+	}
+	if got := stats.Snapshot().TotalCostMicrodollars; got != 600 {
+		t.Fatalf("total cost after filling missing = %d, want 600", got)
+	}
+	if updated := stats.RecomputeCosts(false); updated != 1 {
+		t.Fatalf("recompute all updated %d requests, want 1", updated)
+	}
+	snapshot = stats.Snapshot()
+	if snapshot.TotalCostMicrodollars != 700 || snapshot.APIs["k"].Models["m"].TotalCostMicrodollars != 600 {
+		t.Fatalf("unexpected costs after full recompute: %+v", snapshot.APIs["k"])
+	}
+}
@@ -0,0 +1,131 @@
+package usage
+
+import (
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+// QuotaUsage reports what a client API key consumed in the current UTC day and month.
+type QuotaUsage struct {
+	Day             string `json:"day"`
+	Month           string `json:"month"`
+	RequestsToday   int64  `json:"requests_today"`
+	FailedToday     int64  `json:"failed_today"`
+	TokensToday     int64  `json:"tokens_today"`
+	TokensThisMonth int64  `json:"tokens_this_month"`
+}
+
+// QuotaViolation describes the first quota limit a client API key has reached.
+type QuotaViolation struct {
+	Limit    string    `json:"limit"`
+	Max      int64     `json:"max"`
+	Used     int64     `json:"used"`
+	ResetsAt time.Time `json:"resets_at"`
+}
+
+// quotaCounters tracks per-key usage for the most recent UTC day and month seen.
+type quotaCounters struct {
+	day         string
+	month       string
+	requests    int64
+	failed      int64
+	tokens      int64
+	monthTokens int64
+}
+
+// trackQuotaUsage adds detail to the per-key quota counters. Records for a day or month
+// older than the one being tracked do not affect the current counters. Callers hold s.mu.
+func (s *RequestStatistics) trackQuotaUsage(apiName string, detail RequestDetail) {
+	timestamp := detail.Timestamp.UTC()
+	day, month := timestamp.Format("2006-01-02"), timestamp.Format("2006-01")
+	counters, ok := s.quotas[apiName]
+	if !ok {
+		counters = &quotaCounters{}
+		s.quotas[apiName] = counters
+	}
+	tokens := max(detail.Tokens.TotalTokens, 0)
+	if month > counters.month {
+		counters.month = month
+		counters.monthTokens = 0
+	}
+	if month == counters.month {
+		counters.monthTokens += tokens
+	}
+	if day > counters.day {
+		counters.day = day
+		counters.requests, counters.failed, counters.tokens = 0, 0, 0
+	}
+	if day == counters.day {
+		counters.requests++
+		if detail.Failed {
+			counters.failed++
+		}
+		counters.tokens += tokens
+	}
+}
+
+// trackQuotaTokens adds tokens that arrived late for a request recorded at timestamp.
+func (s *RequestStatistics) trackQuotaTokens(apiName string, timestamp time.Time, tokens int64) {
+	counters, ok := s.quotas[apiName]
+	if !ok || tokens <= 0 {
+		return
+	}
+	timestamp = timestamp.UTC()
+	if timestamp.Format("2006-01") == counters.month {
+		counters.monthTokens += tokens
+	}
+	if timestamp.Format("2006-01-02") == counters.day {
+		counters.tokens += tokens
+	}
+}
+
+// QuotaUsage returns the usage recorded for apiKey in the UTC day and month containing now,
+// including usage recorded under keys aliased to the same key.
+func (s *RequestStatistics) QuotaUsage(apiKey string, now time.Time) QuotaUsage {
+	now = now.UTC()
+	result := QuotaUsage{Day: now.Format("2006-01-02"), Month: now.Format("2006-01")}
+	if s == nil {
+		return result
+	}
+	canonical := CanonicalAPIKey(apiKey)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for key, counters := range s.quotas {
+		if key != apiKey && CanonicalAPIKey(key) != canonical {
+			continue
+		}
+		if counters.day == result.Day {
+			result.RequestsToday += counters.requests
+			result.FailedToday += counters.failed
+			result.TokensToday += counters.tokens
+		}
+		if counters.month == result.Month {
+			result.TokensThisMonth += counters.monthTokens
+		}
+	}
+	return result
+}
+
+// CheckQuota returns the first limit of quota that used has reached at now, or nil when the
+// key may proceed. Failed requests only count toward the request limit when the quota says so.
+func CheckQuota(quota config.UsageQuota, used QuotaUsage, now time.Time) *QuotaViolation {
+	now = now.UTC()
+	y, m, d := now.Date()
+	nextDay := time.Date(y, m, d+1, 0, 0, 0, 0, time.UTC)
+	nextMonth := time.Date(y, m+1, 1, 0, 0, 0, 0, time.UTC)
+
+	requests := used.RequestsToday
+	if !quota.CountFailedRequests {
+		requests -= used.FailedToday
+	}
+	switch {
+	case quota.MaxRequestsPerDay > 0 && requests >= quota.MaxRequestsPerDay:
+		return &QuotaViolation{Limit: "max-requests-per-day", Max: quota.MaxRequestsPerDay, Used: requests, ResetsAt: nextDay}
+	case quota.MaxTokensPerDay > 0 && used.TokensToday >= quota.MaxTokensPerDay:
+		return &QuotaViolation{Limit: "max-tokens-per-day", Max: quota.MaxTokensPerDay, Used: used.TokensToday, ResetsAt: nextDay}
+	case quota.MaxTokensPerMonth > 0 && used.TokensThisMonth >= quota.MaxTokensPerMonth:
+		return &QuotaViolation{Limit: "max-tokens-per-month", Max: quota.MaxTokensPerMonth, Used: used.TokensThisMonth, ResetsAt: nextMonth}
+	}
+	return nil
+}
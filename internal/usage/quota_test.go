@@ -0,0 +1,66 @@
+package usage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+)
+
+func TestQuotaUsageRollsOverAtUTCBoundaries(t *testing.T) {
+	stats := NewRequestStatistics()
+	record := func(ts time.Time, tokens int64, failed bool) {
+		stats.Record(context.Background(), coreusage.Record{
+			APIKey:      "k",
+			Model:       "m",
+			RequestedAt: ts,
+			Failed:      failed,
+			Detail:      coreusage.Detail{InputTokens: tokens},
+		})
+	}
+	record(time.Date(2025, 1, 31, 23, 59, 0, 0, time.UTC), 100, false)
+	record(time.Date(2025, 2, 1, 0, 1, 0, 0, time.UTC), 10, false)
+	record(time.Date(2025, 2, 1, 1, 0, 0, 0, time.UTC), 5, true)
+	// A late record for the previous day must not reset the current counters.
+	record(time.Date(2025, 1, 31, 22, 0, 0, 0, time.UTC), 1000, false)
+
+	used := stats.QuotaUsage("k", time.Date(2025, 2, 1, 12, 0, 0, 0, time.UTC))
+	if used.RequestsToday != 2 || used.FailedToday != 1 || used.TokensToday != 15 || used.TokensThisMonth != 15 {
+		t.Fatalf("unexpected usage on Feb 1: %+v", used)
+	}
+	next := stats.QuotaUsage("k", time.Date(2025, 2, 2, 0, 0, 0, 0, time.UTC))
+	if next.RequestsToday != 0 || next.TokensToday != 0 || next.TokensThisMonth != 15 {
+		t.Fatalf("unexpected usage on Feb 2: %+v", next)
+	}
+	if march := stats.QuotaUsage("k", time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)); march.TokensThisMonth != 0 {
+		t.Fatalf("monthly tokens should reset in March: %+v", march)
+	}
+}
+
+func TestCheckQuota(t *testing.T) {
+	now := time.Date(2025, 12, 31, 18, 0, 0, 0, time.UTC)
+	used := QuotaUsage{RequestsToday: 10, FailedToday: 3, TokensToday: 500, TokensThisMonth: 9000}
+
+	quota := config.UsageQuota{APIKey: "k", MaxRequestsPerDay: 10}
+	if v := CheckQuota(quota, used, now); v != nil {
+		t.Fatalf("failed requests should not count by default: %+v", v)
+	}
+	quota.CountFailedRequests = true
+	v := CheckQuota(quota, used, now)
+	if v == nil || v.Limit != "max-requests-per-day" || v.Used != 10 {
+		t.Fatalf("expected request limit violation, got %+v", v)
+	}
+	if want := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC); !v.ResetsAt.Equal(want) {
+		t.Fatalf("resets_at = %v, want %v", v.ResetsAt, want)
+	}
+
+	v = CheckQuota(config.UsageQuota{MaxTokensPerMonth: 9000}, used, now)
+	if v == nil || v.Limit != "max-tokens-per-month" || !v.ResetsAt.Equal(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("expected monthly token violation resetting on Jan 1, got %+v", v)
+	}
+	if v = CheckQuota(config.UsageQuota{MaxTokensPerDay: 501}, used, now); v != nil {
+		t.Fatalf("daily token limit not yet reached: %+v", v)
+	}
+}
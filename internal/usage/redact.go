@@ -0,0 +1,112 @@
+package usage
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+)
+
+// keySecretFile holds the per-install secret used to pseudonymise client API keys and IPs.
+const keySecretFile = ".usage-key-secret"
+
+// redactedKeyPrefix marks API keys replaced by RedactAPIKey.
+const redactedKeyPrefix = "hmac:"
+
+var keySecret atomic.Pointer[[]byte]
+
+// SetKeySecret sets the secret keying the pseudonyms produced by RedactAPIKey and the
+// hashed-ip client metadata mode.
+func SetKeySecret(secret []byte) {
+	stored := append([]byte(nil), secret...)
+	keySecret.Store(&stored)
+}
+
+// currentKeySecret returns the secret set by SetKeySecret, generating a process-local one
+// when none was set.
+func currentKeySecret() []byte {
+	if secret := keySecret.Load(); secret != nil {
+		return *secret
+	}
+	generated := make([]byte, 32)
+	_, _ = rand.Read(generated)
+	keySecret.CompareAndSwap(nil, &generated)
+	return *keySecret.Load()
+}
+
+// ResolveKeySecret returns the secret stored in dir, generating and storing a random one on
+// first use so pseudonyms stay stable across restarts.
+func ResolveKeySecret(dir string) ([]byte, error) {
+	path := filepath.Join(dir, keySecretFile)
+	if data, err := os.ReadFile(path); err == nil {
+		if secret, errDecode := hex.DecodeString(strings.TrimSpace(string(data))); errDecode == nil && len(secret) > 0 {
+			return secret, nil
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("read usage key secret: %w", err)
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, fmt.Errorf("generate usage key secret: %w", err)
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("store usage key secret: %w", err)
+	}
+	if err := os.WriteFile(path, []byte(hex.EncodeToString(secret)+"\n"), 0o600); err != nil {
+		return nil, fmt.Errorf("store usage key secret: %w", err)
+	}
+	return secret, nil
+}
+
+// keyedDigest returns the hex HMAC-SHA256 of value under the install secret, truncated to
+// size bytes.
+func keyedDigest(value string, size int) string {
+	mac := hmac.New(sha256.New, currentKeySecret())
+	mac.Write([]byte(value))
+	return hex.EncodeToString(mac.Sum(nil)[:size])
+}
+
+// RedactAPIKey returns a stable pseudonym for a client API key. Empty and already redacted
+// keys are returned unchanged.
+func RedactAPIKey(key string) string {
+	if key == "" || IsRedactedAPIKey(key) {
+		return key
+	}
+	return redactedKeyPrefix + keyedDigest(key, 12)
+}
+
+// IsRedactedAPIKey reports whether key is a pseudonym produced by RedactAPIKey.
+func IsRedactedAPIKey(key string) bool { return strings.HasPrefix(key, redactedKeyPrefix) }
+
+// RedactRecords returns a copy of records with their API keys redacted.
+func RedactRecords(records []FlatRecord) []FlatRecord {
+	redacted := make([]FlatRecord, len(records))
+	for i, record := range records {
+		record.APIKey = RedactAPIKey(record.APIKey)
+		redacted[i] = record
+	}
+	return redacted
+}
+
+// RedactSnapshot returns snapshot with its per-key statistics listed under redacted keys.
+func RedactSnapshot(snapshot StatisticsSnapshot) StatisticsSnapshot {
+	if len(snapshot.APIs) == 0 {
+		return snapshot
+	}
+	apis := make(map[string]APISnapshot, len(snapshot.APIs))
+	for apiName, stats := range snapshot.APIs {
+		apiName = RedactAPIKey(apiName)
+		if existing, ok := apis[apiName]; ok {
+			stats = mergeAPISnapshot(existing, stats)
+		}
+		apis[apiName] = stats
+	}
+	snapshot.APIs = apis
+	return snapshot
+}
@@ -0,0 +1,118 @@
+package usage
+
+import (
+	"sort"
+	"time"
+)
+
+// ResetMarker records a reset so it can be replayed when persisted usage is loaded.
+type ResetMarker struct {
+	At time.Time `json:"at"`
+	// APIKey limits the reset to one client API key; empty resets every key.
+	APIKey string `json:"api_key,omitempty"`
+}
+
+// Reset moves every client request of scope (an API key, or every key when empty) that
+// started at or before at out of the live statistics into the archive, and returns the
+// archived requests. Requests recorded later that started before at are archived on
+// arrival, so the boundary depends only on request timestamps. Quota counters are left
+// untouched because quotas follow calendar days and months.
+func (s *RequestStatistics) Reset(marker ResetMarker) []FlatRecord {
+	archived := make([]FlatRecord, 0)
+	if s == nil {
+		return archived
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if marker.At.After(s.resets[marker.APIKey]) {
+		s.resets[marker.APIKey] = marker.At
+	}
+	cutoff := marker.At.Add(time.Nanosecond)
+	for apiName, stats := range s.apis {
+		if marker.APIKey != "" && apiName != marker.APIKey && CanonicalAPIKey(apiName) != marker.APIKey {
+			continue
+		}
+		evictAPIStats(stats, cutoff, func(modelName string, detail RequestDetail) {
+			s.forgetDetail(detail)
+			archived = append(archived, FlatRecord{APIKey: apiName, Model: modelName, RequestDetail: detail})
+		})
+		if len(stats.Models) == 0 {
+			delete(s.apis, apiName)
+		}
+	}
+	sort.SliceStable(archived, func(i, j int) bool { return archived[i].Timestamp.Before(archived[j].Timestamp) })
+	for _, record := range archived {
+		s.archiveRecord(record)
+	}
+	return archived
+}
+
+// archiveRecord appends record to the archive. Callers hold s.mu.
+func (s *RequestStatistics) archiveRecord(record FlatRecord) {
+	if record.RequestID != "" {
+		if s.archiveIndex == nil {
+			s.archiveIndex = make(map[string]int)
+		}
+		s.archiveIndex[record.RequestID] = len(s.archive)
+	}
+	s.archive = append(s.archive, record)
+}
+
+// reindexArchive rebuilds the request ID index after the archive was pruned. Callers hold s.mu.
+func (s *RequestStatistics) reindexArchive() {
+	s.archiveIndex = make(map[string]int, len(s.archive))
+	for i, record := range s.archive {
+		if record.RequestID != "" {
+			s.archiveIndex[record.RequestID] = i
+		}
+	}
+}
+
+// archivedAt reports whether a request of apiName that started at timestamp falls before a
+// reset covering that key. Callers hold s.mu.
+func (s *RequestStatistics) archivedAt(apiName string, timestamp time.Time) bool {
+	for _, key := range []string{"", apiName, CanonicalAPIKey(apiName)} {
+		if at, ok := s.resets[key]; ok && !timestamp.After(at) {
+			return true
+		}
+	}
+	return false
+}
+
+// ArchivedRecords returns the archived requests between from and to as flat records ordered
+// like Records, with aliased API keys reported under the key they alias. Zero bounds are
+// unbounded.
+func (s *RequestStatistics) ArchivedRecords(from, to time.Time) []FlatRecord {
+	records := make([]FlatRecord, 0)
+	if s == nil {
+		return records
+	}
+	s.mu.RLock()
+	for _, record := range s.archive {
+		if !from.IsZero() && record.Timestamp.Before(from) {
+			continue
+		}
+		if !to.IsZero() && record.Timestamp.After(to) {
+			continue
+		}
+		record.APIKey = CanonicalAPIKey(record.APIKey)
+		records = append(records, record)
+	}
+	s.mu.RUnlock()
+	sort.SliceStable(records, func(i, j int) bool { return recordLess(records[i], records[j]) })
+	return records
+}
+
+// MergeRecords merges two lists ordered as Records returns them into one ordered list.
+func MergeRecords(a, b []FlatRecord) []FlatRecord {
+	merged := make([]FlatRecord, 0, len(a)+len(b))
+	for len(a) > 0 && len(b) > 0 {
+		if recordLess(b[0], a[0]) {
+			merged, b = append(merged, b[0]), b[1:]
+		} else {
+			merged, a = append(merged, a[0]), a[1:]
+		}
+	}
+	return append(append(merged, a...), b...)
+}
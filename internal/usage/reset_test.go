@@ -0,0 +1,130 @@
+package usage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+)
+
+func TestResetArchivesScopedRequests(t *testing.T) {
+	base := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	stats := NewRequestStatistics()
+	record := func(key string, ts time.Time) {
+		stats.Record(context.Background(), coreusage.Record{
+			APIKey:      key,
+			Model:       "m",
+			RequestedAt: ts,
+			Detail:      coreusage.Detail{InputTokens: 10},
+		})
+	}
+	record("a", base)
+	record("b", base)
+
+	archived := stats.Reset(ResetMarker{At: base.Add(time.Minute), APIKey: "a"})
+	if len(archived) != 1 || archived[0].APIKey != "a" {
+		t.Fatalf("archived = %+v, want the single request of key a", archived)
+	}
+	// A request that started before the reset but is recorded afterwards is archived too.
+	record("a", base.Add(30*time.Second))
+	record("a", base.Add(2*time.Minute))
+
+	live := stats.Records(time.Time{}, time.Time{})
+	if len(live) != 2 || live[0].APIKey != "b" || !live[1].Timestamp.Equal(base.Add(2*time.Minute)) {
+		t.Fatalf("live records = %+v", live)
+	}
+	if got := stats.Snapshot().TotalRequests; got != 2 {
+		t.Fatalf("live total = %d, want 2", got)
+	}
+	if got := stats.ArchivedRecords(time.Time{}, time.Time{}); len(got) != 2 {
+		t.Fatalf("archived records = %d, want 2", len(got))
+	}
+	if got := stats.ArchivedRecords(base.Add(time.Second), time.Time{}); len(got) != 1 {
+		t.Fatalf("archived records after from = %d, want 1", len(got))
+	}
+	// Resets leave quotas alone, including for requests archived on arrival.
+	if used := stats.QuotaUsage("a", base); used.RequestsToday != 3 || used.TokensToday != 30 {
+		t.Fatalf("quota usage of key a = %+v, want 3 requests and 30 tokens", used)
+	}
+}
+
+func TestResetReplayedFromUsageStore(t *testing.T) {
+	dir := t.TempDir()
+	base := time.Now().Add(-time.Hour).Truncate(time.Second)
+	stats := NewRequestStatistics()
+	persistence, err := OpenUsageStore(config.UsageStore{Backend: "jsonl", Dir: dir}, stats)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		stats.Record(context.Background(), coreusage.Record{APIKey: "k", Model: "m", RequestedAt: base.Add(time.Duration(i) * time.Minute)})
+	}
+	marker := ResetMarker{At: base.Add(90 * time.Second)}
+	if err = persistence.AppendReset(marker); err != nil {
+		t.Fatalf("append reset: %v", err)
+	}
+	stats.Reset(marker)
+	if err = persistence.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	reloaded := NewRequestStatistics()
+	reopened, err := OpenUsageStore(config.UsageStore{Backend: "jsonl", Dir: dir}, reloaded)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer func() { _ = reopened.Close() }()
+	if got := reloaded.Snapshot().TotalRequests; got != 1 {
+		t.Fatalf("live total after reload = %d, want 1", got)
+	}
+	if got := reloaded.ArchivedRecords(time.Time{}, time.Time{}); len(got) != 2 {
+		t.Fatalf("archived after reload = %d, want 2", len(got))
+	}
+}
+
+func TestMergeSnapshotRespectsResets(t *testing.T) {
+	base := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	stats := NewRequestStatistics()
+	for i := 0; i < 2; i++ {
+		stats.Record(context.Background(), coreusage.Record{APIKey: "k", Model: "m", RequestedAt: base.Add(time.Duration(i) * time.Hour)})
+	}
+	export := stats.Snapshot()
+	stats.Reset(ResetMarker{At: base.Add(30 * time.Minute)})
+
+	// Re-importing an export taken before the reset neither revives the archived request nor
+	// counts it twice.
+	if result := stats.MergeSnapshot(export); result.Added != 0 || result.Skipped != 2 {
+		t.Fatalf("re-import: %+v, want both skipped", result)
+	}
+	fresh := StatisticsSnapshot{APIs: map[string]APISnapshot{"k": {Models: map[string]ModelSnapshot{
+		"m": {Details: []RequestDetail{{Timestamp: base.Add(time.Minute), RequestID: "pre-reset"}}},
+	}}}}
+	if result := stats.MergeSnapshot(fresh); result.Added != 1 {
+		t.Fatalf("import: %+v, want 1 added", result)
+	}
+	if got := stats.Snapshot().TotalRequests; got != 1 {
+		t.Fatalf("live total = %d, want 1", got)
+	}
+	if got := stats.ArchivedRecords(time.Time{}, time.Time{}); len(got) != 2 {
+		t.Fatalf("archived = %d, want 2", len(got))
+	}
+}
+
+func TestMergeRecordsKeepsOrder(t *testing.T) {
+	base := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	at := func(minute int, id string) FlatRecord {
+		return FlatRecord{APIKey: "k", Model: "m", RequestDetail: RequestDetail{Timestamp: base.Add(time.Duration(minute) * time.Minute), RequestID: id}}
+	}
+	merged := MergeRecords([]FlatRecord{at(1, "a"), at(3, "c"), at(3, "e")}, []FlatRecord{at(0, "x"), at(3, "d"), at(4, "f")})
+	want := []string{"x", "a", "c", "d", "e", "f"}
+	if len(merged) != len(want) {
+		t.Fatalf("merged %d records, want %d", len(merged), len(want))
+	}
+	for i, id := range want {
+		if merged[i].RequestID != id {
+			t.Fatalf("merged[%d] = %s, want %s", i, merged[i].RequestID, id)
+		}
+	}
+}
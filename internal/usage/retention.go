@@ -0,0 +1,144 @@
+package usage
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// horizonEvictionInterval is how often the shared statistics are trimmed to the horizon.
+const horizonEvictionInterval = time.Hour
+
+var (
+	statisticsHorizon   atomic.Int64
+	horizonEvictionOnce sync.Once
+)
+
+// SetStatisticsHorizon sets how long request details are kept in memory. Older details
+// and their contribution to the aggregates are evicted from the shared statistics right
+// away and then hourly. Zero or a negative value keeps everything.
+func SetStatisticsHorizon(horizon time.Duration) {
+	if horizon < 0 {
+		horizon = 0
+	}
+	statisticsHorizon.Store(int64(horizon))
+	if horizon == 0 {
+		return
+	}
+	evictBeyondHorizon(defaultRequestStatistics)
+	horizonEvictionOnce.Do(func() {
+		go func() {
+			ticker := time.NewTicker(horizonEvictionInterval)
+			defer ticker.Stop()
+			for range ticker.C {
+				evictBeyondHorizon(defaultRequestStatistics)
+			}
+		}()
+	})
+}
+
+func evictBeyondHorizon(s *RequestStatistics) {
+	horizon := time.Duration(statisticsHorizon.Load())
+	if horizon <= 0 {
+		return
+	}
+	if evicted := s.EvictBefore(time.Now().Add(-horizon)); evicted > 0 {
+		log.Debugf("usage statistics: evicted %d requests older than %s", evicted, horizon)
+	}
+}
+
+// EvictBefore removes request details recorded before cutoff and subtracts them from every
+// aggregate, dropping models and API keys left without requests. It returns the number of
+// client requests evicted; system and archived requests are trimmed too but not counted.
+func (s *RequestStatistics) EvictBefore(cutoff time.Time) int64 {
+	if s == nil {
+		return 0
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var evicted int64
+	for apiName, stats := range s.apis {
+		evictAPIStats(stats, cutoff, func(_ string, detail RequestDetail) {
+			evicted++
+			s.forgetDetail(detail)
+		})
+		if len(stats.Models) == 0 {
+			delete(s.apis, apiName)
+		}
+	}
+	for purpose, stats := range s.system {
+		evictAPIStats(stats, cutoff, func(string, RequestDetail) {})
+		if len(stats.Models) == 0 {
+			delete(s.system, purpose)
+		}
+	}
+	archived := s.archive[:0]
+	for _, record := range s.archive {
+		if !record.Timestamp.Before(cutoff) {
+			archived = append(archived, record)
+		}
+	}
+	if len(archived) < len(s.archive) {
+		clear(s.archive[len(archived):])
+		s.archive = archived
+		s.reindexArchive()
+	}
+	return evicted
+}
+
+// evictAPIStats drops details older than cutoff from stats, calling evict with the model
+// name of each one.
+func evictAPIStats(stats *apiStats, cutoff time.Time, evict func(string, RequestDetail)) {
+	for modelName, modelStatsValue := range stats.Models {
+		kept := modelStatsValue.Details[:0]
+		for _, detail := range modelStatsValue.Details {
+			if !detail.Timestamp.Before(cutoff) {
+				kept = append(kept, detail)
+				continue
+			}
+			modelStatsValue.TotalRequests--
+			modelStatsValue.TotalTokens -= detail.Tokens.TotalTokens
+			modelStatsValue.TotalCost -= detail.CostMicrodollars
+			stats.TotalRequests--
+			stats.TotalTokens -= detail.Tokens.TotalTokens
+			stats.TotalCost -= detail.CostMicrodollars
+			evict(modelName, detail)
+		}
+		clear(modelStatsValue.Details[len(kept):])
+		modelStatsValue.Details = kept
+		if len(kept) == 0 {
+			delete(stats.Models, modelName)
+		}
+	}
+}
+
+// forgetDetail reverses the client-facing totals added when detail was recorded.
+func (s *RequestStatistics) forgetDetail(detail RequestDetail) {
+	totalTokens := detail.Tokens.TotalTokens
+	s.totalRequests--
+	if detail.Failed {
+		s.failureCount--
+	} else {
+		s.successCount--
+	}
+	s.totalTokens -= totalTokens
+	s.totalCost -= detail.CostMicrodollars
+
+	dayKey := detail.Timestamp.Format("2006-01-02")
+	hourKey := detail.Timestamp.Hour()
+	if s.requestsByDay[dayKey]--; s.requestsByDay[dayKey] <= 0 {
+		delete(s.requestsByDay, dayKey)
+		delete(s.tokensByDay, dayKey)
+	} else {
+		s.tokensByDay[dayKey] -= totalTokens
+	}
+	if s.requestsByHour[hourKey]--; s.requestsByHour[hourKey] <= 0 {
+		delete(s.requestsByHour, hourKey)
+		delete(s.tokensByHour, hourKey)
+	} else {
+		s.tokensByHour[hourKey] -= totalTokens
+	}
+}
@@ -0,0 +1,48 @@
+package usage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+)
+
+func TestEvictBeforeRemovesOldDetailsFromAggregates(t *testing.T) {
+	stats := NewRequestStatistics()
+	cutoff := time.Date(2025, 5, 1, 0, 0, 0, 0, time.UTC)
+	record := func(apiKey, model string, ts time.Time, tokens int64, failed bool) {
+		stats.Record(context.Background(), coreusage.Record{
+			APIKey:      apiKey,
+			Model:       model,
+			RequestedAt: ts,
+			Failed:      failed,
+			Detail:      coreusage.Detail{InputTokens: tokens},
+		})
+	}
+	record("old-key", "m", cutoff.Add(-48*time.Hour), 100, false)
+	record("k", "m", cutoff.Add(-time.Hour), 10, true)
+	record("k", "m", cutoff.Add(time.Hour), 1, false)
+	record("k", "other", cutoff.Add(-2*time.Hour), 5, false)
+
+	if evicted := stats.EvictBefore(cutoff); evicted != 3 {
+		t.Fatalf("evicted = %d, want 3", evicted)
+	}
+	snapshot := stats.Snapshot()
+	if snapshot.TotalRequests != 1 || snapshot.SuccessCount != 1 || snapshot.FailureCount != 0 || snapshot.TotalTokens != 1 {
+		t.Fatalf("unexpected totals after eviction: %+v", snapshot)
+	}
+	if _, ok := snapshot.APIs["old-key"]; ok {
+		t.Fatal("API key without remaining requests should be dropped")
+	}
+	api := snapshot.APIs["k"]
+	if _, ok := api.Models["other"]; ok || api.TotalRequests != 1 || len(api.Models["m"].Details) != 1 {
+		t.Fatalf("unexpected API stats after eviction: %+v", api)
+	}
+	if len(snapshot.RequestsByDay) != 1 || snapshot.TokensByDay["2025-05-01"] != 1 {
+		t.Fatalf("unexpected daily buckets after eviction: %+v / %+v", snapshot.RequestsByDay, snapshot.TokensByDay)
+	}
+	if snapshot.RequestsByHour["01"] != 1 || len(snapshot.RequestsByHour) != 1 {
+		t.Fatalf("unexpected hourly buckets after eviction: %+v", snapshot.RequestsByHour)
+	}
+}
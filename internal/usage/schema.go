@@ -0,0 +1,106 @@
+package usage
+
+import (
+	"strconv"
+	"time"
+)
+
+// SchemaVersion identifies the layout of a flattened usage record as exposed by
+// the export endpoints. It must be bumped whenever a column is added to recordColumns.
+const SchemaVersion = 9
+
+// SchemaColumn describes a single column of a flattened usage record.
+type SchemaColumn struct {
+	Name        string `json:"name"`
+	Type        string `json:"type"`
+	Description string `json:"description"`
+	Nullable    bool   `json:"nullable"`
+	Since       int    `json:"since"`
+	// value renders the column of a record for exports and groupings.
+	value func(FlatRecord) string
+}
+
+// Schema is the machine-readable description of the usage record layout.
+type Schema struct {
+	Version int            `json:"version"`
+	Columns []SchemaColumn `json:"columns"`
+}
+
+// recordColumns lists the flattened record layout in export order, with how each column
+// is read from a record. Columns are only ever appended; Since records the schema version
+// that introduced each column.
+var recordColumns = []SchemaColumn{
+	{Name: "api_key", Type: "string", Description: "Client API key or request identifier the usage is attributed to", Since: 1,
+		value: func(r FlatRecord) string { return r.APIKey }},
+	{Name: "model", Type: "string", Description: "Model name requested by the client", Since: 1,
+		value: func(r FlatRecord) string { return r.Model }},
+	{Name: "timestamp", Type: "timestamp", Description: "Time the request was issued (RFC3339Nano)", Since: 1,
+		value: func(r FlatRecord) string { return r.Timestamp.Format(time.RFC3339Nano) }},
+	{Name: "source", Type: "string", Description: "Upstream account, project or key that served the request", Nullable: true, Since: 1,
+		value: func(r FlatRecord) string { return r.Source }},
+	{Name: "auth_index", Type: "string", Description: "Index of the credential used for the request", Nullable: true, Since: 1,
+		value: func(r FlatRecord) string { return r.AuthIndex }},
+	{Name: "failed", Type: "boolean", Description: "Whether the request failed", Since: 1,
+		value: func(r FlatRecord) string { return strconv.FormatBool(r.Failed) }},
+	{Name: "input_tokens", Type: "integer", Description: "Prompt tokens consumed", Since: 1,
+		value: intColumn(func(r FlatRecord) int64 { return r.Tokens.InputTokens })},
+	{Name: "output_tokens", Type: "integer", Description: "Completion tokens produced", Since: 1,
+		value: intColumn(func(r FlatRecord) int64 { return r.Tokens.OutputTokens })},
+	{Name: "reasoning_tokens", Type: "integer", Description: "Reasoning/thinking tokens produced", Since: 1,
+		value: intColumn(func(r FlatRecord) int64 { return r.Tokens.ReasoningTokens })},
+	{Name: "cached_tokens", Type: "integer", Description: "Prompt tokens served from cache", Since: 1,
+		value: intColumn(func(r FlatRecord) int64 { return r.Tokens.CachedTokens })},
+	{Name: "total_tokens", Type: "integer", Description: "Total tokens billed for the request", Since: 1,
+		value: intColumn(func(r FlatRecord) int64 { return r.Tokens.TotalTokens })},
+	{Name: "purpose", Type: "string", Description: "Reason for a system-initiated request (model_refresh, token_refresh, probe)", Nullable: true, Since: 2,
+		value: func(r FlatRecord) string { return r.Purpose }},
+	{Name: "cost_microdollars", Type: "integer", Description: "Estimated cost in millionths of a US dollar at the pricing in effect when recorded", Since: 3,
+		value: intColumn(func(r FlatRecord) int64 { return r.CostMicrodollars })},
+	{Name: "duration_ms", Type: "integer", Description: "Upstream latency in milliseconds; 0 when unknown", Nullable: true, Since: 4,
+		value: intColumn(func(r FlatRecord) int64 { return r.DurationMS })},
+	{Name: "request_id", Type: "string", Description: "Unique identifier of the request; empty for records imported from older exports", Nullable: true, Since: 5,
+		value: func(r FlatRecord) string { return r.RequestID }},
+	{Name: "status_code", Type: "integer", Description: "Upstream HTTP status of a failed request; 0 when successful or unknown", Nullable: true, Since: 6,
+		value: intColumn(func(r FlatRecord) int64 { return int64(r.StatusCode) })},
+	{Name: "error_type", Type: "string", Description: "Failure class (rate_limit, auth, timeout, canceled, upstream, invalid_request, other)", Nullable: true, Since: 6,
+		value: func(r FlatRecord) string { return r.ErrorType }},
+	{Name: "client_ip", Type: "string", Description: "Client IP address, truncated or hashed when configured", Nullable: true, Since: 7,
+		value: func(r FlatRecord) string { return r.ClientIP }},
+	{Name: "user_agent", Type: "string", Description: "User-Agent header sent by the client", Nullable: true, Since: 7,
+		value: func(r FlatRecord) string { return r.UserAgent }},
+	{Name: "endpoint", Type: "string", Description: "HTTP method and route the client called", Nullable: true, Since: 7,
+		value: func(r FlatRecord) string { return r.Endpoint }},
+	{Name: "instance_id", Type: "string", Description: "Proxy instance that recorded the request; empty for records from older exports", Nullable: true, Since: 8,
+		value: func(r FlatRecord) string { return r.InstanceID }},
+	{Name: "provider", Type: "string", Description: "Executor that served the request (claude, codex, gemini, ...); empty for records from older exports", Nullable: true, Since: 9,
+		value: func(r FlatRecord) string { return r.Provider }},
+}
+
+// recordColumnsByName indexes recordColumns for lookups by name.
+var recordColumnsByName = func() map[string]SchemaColumn {
+	byName := make(map[string]SchemaColumn, len(recordColumns))
+	for _, column := range recordColumns {
+		byName[column.Name] = column
+	}
+	return byName
+}()
+
+func intColumn(get func(FlatRecord) int64) func(FlatRecord) string {
+	return func(r FlatRecord) string { return strconv.FormatInt(get(r), 10) }
+}
+
+// DescribeSchema returns the usage record schema. When version is positive only the
+// columns available at that schema version are listed; otherwise the current layout is returned.
+func DescribeSchema(version int) Schema {
+	if version <= 0 || version > SchemaVersion {
+		version = SchemaVersion
+	}
+	columns := make([]SchemaColumn, 0, len(recordColumns))
+	for _, column := range recordColumns {
+		if column.Since > version {
+			continue
+		}
+		columns = append(columns, column)
+	}
+	return Schema{Version: version, Columns: columns}
+}
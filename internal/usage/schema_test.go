@@ -0,0 +1,66 @@
+package usage
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRecordColumnsCoverRequestDetail(t *testing.T) {
+	columns := make(map[string]SchemaColumn, len(recordColumns))
+	for _, column := range recordColumns {
+		if _, exists := columns[column.Name]; exists {
+			t.Fatalf("duplicate schema column %q", column.Name)
+		}
+		if column.value == nil {
+			t.Fatalf("column %q has no value accessor", column.Name)
+		}
+		if column.Since < 1 || column.Since > SchemaVersion {
+			t.Fatalf("column %q has since=%d outside 1..%d", column.Name, column.Since, SchemaVersion)
+		}
+		columns[column.Name] = column
+	}
+
+	var fields []string
+	collectJSONFields(reflect.TypeOf(RequestDetail{}), &fields)
+	fields = append(fields, "api_key", "model")
+	for _, field := range fields {
+		if _, ok := columns[field]; !ok {
+			t.Errorf("RequestDetail field %q has no schema column", field)
+		}
+	}
+	if len(fields) != len(columns) {
+		t.Errorf("schema lists %d columns but RequestDetail flattens to %d fields", len(columns), len(fields))
+	}
+}
+
+func TestDescribeSchemaVersion(t *testing.T) {
+	current := DescribeSchema(0)
+	if current.Version != SchemaVersion {
+		t.Fatalf("version = %d, want %d", current.Version, SchemaVersion)
+	}
+	if len(current.Columns) != len(recordColumns) {
+		t.Fatalf("columns = %d, want %d", len(current.Columns), len(recordColumns))
+	}
+	first := DescribeSchema(1)
+	for _, column := range first.Columns {
+		if column.Since > 1 {
+			t.Fatalf("column %q introduced in %d listed for version 1", column.Name, column.Since)
+		}
+	}
+}
+
+func collectJSONFields(typ reflect.Type, out *[]string) {
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "" || name == "-" {
+			continue
+		}
+		if field.Type.Kind() == reflect.Struct && field.Type.PkgPath() == typ.PkgPath() {
+			collectJSONFields(field.Type, out)
+			continue
+		}
+		*out = append(*out, name)
+	}
+}
@@ -0,0 +1,155 @@
+package usage
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// maxSeriesBuckets bounds the buckets per series so a wide range with a small bucket cannot
+// produce an unbounded response.
+const maxSeriesBuckets = 10000
+
+// UsageBucket aggregates the requests that started within one time bucket.
+type UsageBucket struct {
+	Start           time.Time `json:"start"`
+	Requests        int64     `json:"requests"`
+	Failed          int64     `json:"failed"`
+	InputTokens     int64     `json:"input_tokens"`
+	OutputTokens    int64     `json:"output_tokens"`
+	ReasoningTokens int64     `json:"reasoning_tokens"`
+	CachedTokens    int64     `json:"cached_tokens"`
+	TotalTokens     int64     `json:"total_tokens"`
+}
+
+// UsageSeries is an ordered list of buckets, for one group when the series is grouped.
+type UsageSeries struct {
+	Group   string        `json:"group,omitempty"`
+	Buckets []UsageBucket `json:"buckets"`
+}
+
+// SeriesOptions controls how Series buckets records.
+type SeriesOptions struct {
+	// Bucket is "hour", "day" or "week" (weeks start on Monday).
+	Bucket string
+	// GroupBy optionally splits the series by "model", "api_key" or "instance_id".
+	GroupBy string
+	// From and To bound zero-filling; when zero the first and last record are used.
+	From, To time.Time
+	// ZeroFill emits empty buckets for periods without traffic.
+	ZeroFill bool
+	// Location sets bucket boundaries; nil means UTC.
+	Location *time.Location
+}
+
+// Series buckets records over time, returning one series, or one per group ordered by
+// group name when GroupBy is set.
+func Series(records []FlatRecord, opts SeriesOptions) ([]UsageSeries, error) {
+	loc := opts.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+	var start, next func(time.Time) time.Time
+	switch opts.Bucket {
+	case "hour":
+		start = func(t time.Time) time.Time {
+			t = t.In(loc)
+			return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, loc)
+		}
+		next = func(t time.Time) time.Time { return start(t.Add(time.Hour)) }
+	case "day":
+		start = func(t time.Time) time.Time {
+			t = t.In(loc)
+			return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+		}
+		next = func(t time.Time) time.Time { return time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc) }
+	case "week":
+		start = func(t time.Time) time.Time {
+			t = t.In(loc)
+			offset := (int(t.Weekday()) + 6) % 7
+			return time.Date(t.Year(), t.Month(), t.Day()-offset, 0, 0, 0, 0, loc)
+		}
+		next = func(t time.Time) time.Time { return time.Date(t.Year(), t.Month(), t.Day()+7, 0, 0, 0, 0, loc) }
+	default:
+		return nil, fmt.Errorf("unsupported bucket %q", opts.Bucket)
+	}
+	switch opts.GroupBy {
+	case "", "model", "api_key", "instance_id":
+	default:
+		return nil, fmt.Errorf("unsupported group_by %q", opts.GroupBy)
+	}
+
+	groups := make(map[string]map[time.Time]*UsageBucket)
+	var first, last time.Time
+	for _, record := range records {
+		group := ""
+		if opts.GroupBy != "" {
+			group = record.columnValue(opts.GroupBy)
+		}
+		buckets, ok := groups[group]
+		if !ok {
+			buckets = make(map[time.Time]*UsageBucket)
+			groups[group] = buckets
+		}
+		bucketStart := start(record.Timestamp)
+		if first.IsZero() || bucketStart.Before(first) {
+			first = bucketStart
+		}
+		if bucketStart.After(last) {
+			last = bucketStart
+		}
+		bucket, ok := buckets[bucketStart]
+		if !ok {
+			bucket = &UsageBucket{Start: bucketStart}
+			buckets[bucketStart] = bucket
+		}
+		bucket.Requests++
+		if record.Failed {
+			bucket.Failed++
+		}
+		bucket.InputTokens += record.Tokens.InputTokens
+		bucket.OutputTokens += record.Tokens.OutputTokens
+		bucket.ReasoningTokens += record.Tokens.ReasoningTokens
+		bucket.CachedTokens += record.Tokens.CachedTokens
+		bucket.TotalTokens += record.Tokens.TotalTokens
+	}
+	if len(groups) == 0 && opts.GroupBy == "" {
+		groups[""] = map[time.Time]*UsageBucket{}
+	}
+	if !opts.From.IsZero() {
+		first = start(opts.From)
+	}
+	if !opts.To.IsZero() {
+		last = start(opts.To)
+	}
+
+	names := make([]string, 0, len(groups))
+	for name := range groups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	series := make([]UsageSeries, 0, len(names))
+	for _, name := range names {
+		buckets := groups[name]
+		out := UsageSeries{Group: name, Buckets: make([]UsageBucket, 0, len(buckets))}
+		if opts.ZeroFill && !first.IsZero() {
+			for t := first; !t.After(last); t = next(t) {
+				if len(out.Buckets) == maxSeriesBuckets {
+					return nil, fmt.Errorf("range spans more than %d %s buckets", maxSeriesBuckets, opts.Bucket)
+				}
+				if bucket, ok := buckets[t]; ok {
+					out.Buckets = append(out.Buckets, *bucket)
+				} else {
+					out.Buckets = append(out.Buckets, UsageBucket{Start: t})
+				}
+			}
+		} else {
+			for _, bucket := range buckets {
+				out.Buckets = append(out.Buckets, *bucket)
+			}
+			sort.Slice(out.Buckets, func(i, j int) bool { return out.Buckets[i].Start.Before(out.Buckets[j].Start) })
+		}
+		series = append(series, out)
+	}
+	return series, nil
+}
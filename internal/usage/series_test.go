@@ -0,0 +1,46 @@
+package usage
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSeriesBucketsAndZeroFills(t *testing.T) {
+	base := time.Date(2025, 8, 4, 9, 15, 0, 0, time.UTC) // a Monday
+	records := []FlatRecord{
+		{Model: "a", RequestDetail: RequestDetail{Timestamp: base, Tokens: TokenStats{TotalTokens: 5}}},
+		{Model: "b", RequestDetail: RequestDetail{Timestamp: base.Add(10 * time.Minute), Failed: true}},
+		{Model: "a", RequestDetail: RequestDetail{Timestamp: base.Add(3 * time.Hour), Tokens: TokenStats{TotalTokens: 7}}},
+	}
+
+	series, err := Series(records, SeriesOptions{Bucket: "hour"})
+	if err != nil {
+		t.Fatalf("series: %v", err)
+	}
+	if len(series) != 1 || len(series[0].Buckets) != 2 || series[0].Buckets[0].Requests != 2 || series[0].Buckets[0].Failed != 1 {
+		t.Fatalf("unexpected sparse series: %+v", series)
+	}
+
+	series, err = Series(records, SeriesOptions{Bucket: "hour", ZeroFill: true, GroupBy: "model"})
+	if err != nil {
+		t.Fatalf("series: %v", err)
+	}
+	if len(series) != 2 || series[0].Group != "a" || len(series[0].Buckets) != 4 || len(series[1].Buckets) != 4 {
+		t.Fatalf("zero-filled groups should share the same four hourly buckets: %+v", series)
+	}
+	if series[0].Buckets[1].Requests != 0 || series[0].Buckets[3].TotalTokens != 7 {
+		t.Fatalf("unexpected zero-filled buckets: %+v", series[0].Buckets)
+	}
+
+	series, err = Series(records, SeriesOptions{Bucket: "week", ZeroFill: true, To: base.AddDate(0, 0, 8)})
+	if err != nil {
+		t.Fatalf("series: %v", err)
+	}
+	if got := series[0].Buckets; len(got) != 2 || !got[0].Start.Equal(time.Date(2025, 8, 4, 0, 0, 0, 0, time.UTC)) || got[0].Requests != 3 {
+		t.Fatalf("unexpected weekly buckets: %+v", got)
+	}
+
+	if _, err = Series(records, SeriesOptions{Bucket: "minute"}); err == nil {
+		t.Fatal("expected an error for an unsupported bucket")
+	}
+}
@@ -0,0 +1,165 @@
+package usage
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// SummaryGroupings lists the fields records can be grouped by in a summary.
+var SummaryGroupings = []string{"model", "api_key", "source", "auth_index", "instance_id"}
+
+// SummaryRow aggregates the records sharing one value of the grouping field.
+type SummaryRow struct {
+	Key              string `json:"key"`
+	Requests         int64  `json:"requests"`
+	Failed           int64  `json:"failed"`
+	InputTokens      int64  `json:"input_tokens"`
+	OutputTokens     int64  `json:"output_tokens"`
+	ReasoningTokens  int64  `json:"reasoning_tokens"`
+	CachedTokens     int64  `json:"cached_tokens"`
+	TotalTokens      int64  `json:"total_tokens"`
+	CostMicrodollars int64  `json:"cost_microdollars"`
+	// Latency figures only cover requests recorded with a duration.
+	LatencySamples int64   `json:"latency_samples"`
+	AvgDurationMS  float64 `json:"avg_duration_ms,omitempty"`
+	P95DurationMS  int64   `json:"p95_duration_ms,omitempty"`
+	// FailuresByType counts failed requests per error type; failures recorded without one
+	// are counted as "unknown".
+	FailuresByType map[string]int64 `json:"failures_by_type,omitempty"`
+}
+
+// Summarize groups records by groupBy, one of SummaryGroupings, and sums their counts.
+// Average and p95 latency ignore records without a duration.
+// Rows are ordered by total tokens, then requests, descending, and then by key.
+func Summarize(records []FlatRecord, groupBy string) ([]SummaryRow, error) {
+	valid := false
+	for _, grouping := range SummaryGroupings {
+		if grouping == groupBy {
+			valid = true
+			break
+		}
+	}
+	if !valid {
+		return nil, fmt.Errorf("unsupported group_by %q", groupBy)
+	}
+	return summarize(records, func(record FlatRecord) string { return record.columnValue(groupBy) }), nil
+}
+
+// TopUsage returns at most n rows of the summary grouped by dimension, heaviest first. Ties
+// on tokens and requests are broken by key so results are stable.
+func TopUsage(records []FlatRecord, dimension string, n int) ([]SummaryRow, error) {
+	if n <= 0 {
+		return nil, fmt.Errorf("n must be positive")
+	}
+	rows, err := Summarize(records, dimension)
+	if err != nil {
+		return nil, err
+	}
+	return rows[:min(n, len(rows))], nil
+}
+
+// summarize groups records by the key returned by keyOf.
+func summarize(records []FlatRecord, keyOf func(FlatRecord) string) []SummaryRow {
+	rowsByKey := make(map[string]*SummaryRow)
+	durations := make(map[string][]int64)
+	for _, record := range records {
+		key := keyOf(record)
+		row, ok := rowsByKey[key]
+		if !ok {
+			row = &SummaryRow{Key: key}
+			rowsByKey[key] = row
+		}
+		row.Requests++
+		if record.Failed {
+			row.Failed++
+			errorType := record.ErrorType
+			if errorType == "" {
+				errorType = "unknown"
+			}
+			if row.FailuresByType == nil {
+				row.FailuresByType = make(map[string]int64)
+			}
+			row.FailuresByType[errorType]++
+		}
+		row.InputTokens += record.Tokens.InputTokens
+		row.OutputTokens += record.Tokens.OutputTokens
+		row.ReasoningTokens += record.Tokens.ReasoningTokens
+		row.CachedTokens += record.Tokens.CachedTokens
+		row.TotalTokens += record.Tokens.TotalTokens
+		row.CostMicrodollars += record.CostMicrodollars
+		if record.DurationMS > 0 {
+			durations[key] = append(durations[key], record.DurationMS)
+		}
+	}
+
+	rows := make([]SummaryRow, 0, len(rowsByKey))
+	for key, row := range rowsByKey {
+		if samples := durations[key]; len(samples) > 0 {
+			sort.Slice(samples, func(i, j int) bool { return samples[i] < samples[j] })
+			var sum int64
+			for _, sample := range samples {
+				sum += sample
+			}
+			row.LatencySamples = int64(len(samples))
+			row.AvgDurationMS = float64(sum) / float64(len(samples))
+			// Nearest-rank percentile.
+			row.P95DurationMS = samples[(len(samples)*95+99)/100-1]
+		}
+		rows = append(rows, *row)
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].TotalTokens != rows[j].TotalTokens {
+			return rows[i].TotalTokens > rows[j].TotalTokens
+		}
+		if rows[i].Requests != rows[j].Requests {
+			return rows[i].Requests > rows[j].Requests
+		}
+		return rows[i].Key < rows[j].Key
+	})
+	return rows
+}
+
+// noAuthIndex labels requests recorded without a credential index.
+const noAuthIndex = "(none)"
+
+// AuthUsageSummary aggregates the requests served by one credential.
+type AuthUsageSummary struct {
+	AuthIndex       string `json:"auth_index"`
+	Source          string `json:"source"`
+	Requests        int64  `json:"requests"`
+	Failed          int64  `json:"failed"`
+	InputTokens     int64  `json:"input_tokens"`
+	OutputTokens    int64  `json:"output_tokens"`
+	ReasoningTokens int64  `json:"reasoning_tokens"`
+	CachedTokens    int64  `json:"cached_tokens"`
+	TotalTokens     int64  `json:"total_tokens"`
+}
+
+// SummarizeByAuth groups records by auth_index and source, so each upstream credential's
+// consumption can be compared. Records without an auth_index are grouped under "(none)".
+func SummarizeByAuth(records []FlatRecord) []AuthUsageSummary {
+	rows := summarize(records, func(record FlatRecord) string {
+		authIndex := record.AuthIndex
+		if authIndex == "" {
+			authIndex = noAuthIndex
+		}
+		return authIndex + "\x00" + record.Source
+	})
+	out := make([]AuthUsageSummary, 0, len(rows))
+	for _, row := range rows {
+		authIndex, source, _ := strings.Cut(row.Key, "\x00")
+		out = append(out, AuthUsageSummary{
+			AuthIndex:       authIndex,
+			Source:          source,
+			Requests:        row.Requests,
+			Failed:          row.Failed,
+			InputTokens:     row.InputTokens,
+			OutputTokens:    row.OutputTokens,
+			ReasoningTokens: row.ReasoningTokens,
+			CachedTokens:    row.CachedTokens,
+			TotalTokens:     row.TotalTokens,
+		})
+	}
+	return out
+}
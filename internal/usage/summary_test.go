@@ -0,0 +1,106 @@
+package usage
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSummarizeGroupsRecords(t *testing.T) {
+	base := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	records := []FlatRecord{
+		{APIKey: "a", Model: "m1", RequestDetail: RequestDetail{Timestamp: base, Source: "s1", Tokens: TokenStats{InputTokens: 10, TotalTokens: 10}}},
+		{APIKey: "b", Model: "m1", RequestDetail: RequestDetail{Timestamp: base, Source: "s2", Failed: true, StatusCode: 429, ErrorType: "rate_limit"}},
+		{APIKey: "a", Model: "m2", RequestDetail: RequestDetail{Timestamp: base, Source: "s1", Tokens: TokenStats{OutputTokens: 30, TotalTokens: 30}}},
+	}
+
+	rows, err := Summarize(records, "model")
+	if err != nil {
+		t.Fatalf("summarize: %v", err)
+	}
+	if len(rows) != 2 || rows[0].Key != "m2" || rows[1].Key != "m1" {
+		t.Fatalf("unexpected model rows: %+v", rows)
+	}
+	if rows[1].Requests != 2 || rows[1].Failed != 1 || rows[1].InputTokens != 10 || rows[1].FailuresByType["rate_limit"] != 1 {
+		t.Fatalf("unexpected m1 row: %+v", rows[1])
+	}
+
+	rows, err = Summarize(records, "api_key")
+	if err != nil {
+		t.Fatalf("summarize: %v", err)
+	}
+	if len(rows) != 2 || rows[0].Key != "a" || rows[0].TotalTokens != 40 {
+		t.Fatalf("unexpected api_key rows: %+v", rows)
+	}
+
+	if rows[0].LatencySamples != 0 || rows[0].AvgDurationMS != 0 {
+		t.Fatalf("records without a duration should not produce latency: %+v", rows[0])
+	}
+
+	if _, err = Summarize(records, "timestamp"); err == nil {
+		t.Fatal("expected an error for an unsupported grouping")
+	}
+}
+
+func TestSummarizeLatencyIgnoresUnknownDurations(t *testing.T) {
+	records := make([]FlatRecord, 0, 21)
+	for i := int64(1); i <= 20; i++ {
+		records = append(records, FlatRecord{Model: "m", RequestDetail: RequestDetail{AuthIndex: "1", DurationMS: i * 100}})
+	}
+	records = append(records, FlatRecord{Model: "m", RequestDetail: RequestDetail{AuthIndex: "1"}})
+
+	rows, err := Summarize(records, "auth_index")
+	if err != nil {
+		t.Fatalf("summarize: %v", err)
+	}
+	row := rows[0]
+	if row.Requests != 21 || row.LatencySamples != 20 {
+		t.Fatalf("unexpected counts: %+v", row)
+	}
+	if row.AvgDurationMS != 1050 || row.P95DurationMS != 1900 {
+		t.Fatalf("avg = %v, p95 = %d; want 1050 and 1900", row.AvgDurationMS, row.P95DurationMS)
+	}
+}
+
+func TestSummarizeByAuthGroupsCredentials(t *testing.T) {
+	records := []FlatRecord{
+		{Model: "m", RequestDetail: RequestDetail{AuthIndex: "1", Source: "a@example.com", Tokens: TokenStats{TotalTokens: 5}}},
+		{Model: "m", RequestDetail: RequestDetail{AuthIndex: "1", Source: "a@example.com", Failed: true}},
+		{Model: "m", RequestDetail: RequestDetail{AuthIndex: "2", Source: "b@example.com", Tokens: TokenStats{TotalTokens: 50}}},
+		{Model: "m", RequestDetail: RequestDetail{Source: "client-key"}},
+	}
+
+	rows := SummarizeByAuth(records)
+	if len(rows) != 3 {
+		t.Fatalf("expected 3 credentials, got %+v", rows)
+	}
+	if rows[0].AuthIndex != "2" || rows[0].TotalTokens != 50 {
+		t.Fatalf("heaviest credential should come first: %+v", rows[0])
+	}
+	if rows[1].AuthIndex != "1" || rows[1].Source != "a@example.com" || rows[1].Requests != 2 || rows[1].Failed != 1 {
+		t.Fatalf("unexpected credential row: %+v", rows[1])
+	}
+	if rows[2].AuthIndex != "(none)" || rows[2].Source != "client-key" {
+		t.Fatalf("records without auth_index should use the sentinel: %+v", rows[2])
+	}
+}
+
+func TestTopUsageLimitsAndBreaksTies(t *testing.T) {
+	records := []FlatRecord{
+		{Model: "b", RequestDetail: RequestDetail{Tokens: TokenStats{TotalTokens: 10}}},
+		{Model: "a", RequestDetail: RequestDetail{Tokens: TokenStats{TotalTokens: 10}}},
+		{Model: "c", RequestDetail: RequestDetail{Tokens: TokenStats{TotalTokens: 99}}},
+	}
+	rows, err := TopUsage(records, "model", 2)
+	if err != nil {
+		t.Fatalf("top: %v", err)
+	}
+	if len(rows) != 2 || rows[0].Key != "c" || rows[1].Key != "a" {
+		t.Fatalf("unexpected top rows: %+v", rows)
+	}
+	if rows, _ = TopUsage(records, "model", 50); len(rows) != 3 {
+		t.Fatalf("n beyond the group count should return every group, got %d", len(rows))
+	}
+	if _, err = TopUsage(records, "model", 0); err == nil {
+		t.Fatal("expected an error for n <= 0")
+	}
+}
@@ -0,0 +1,157 @@
+package usage
+
+import (
+	"sort"
+	"time"
+)
+
+// trendTopModels is the number of models broken out in a TrendSummary.
+const trendTopModels = 5
+
+// TrendWindow is a closed time range compared by a TrendSummary.
+type TrendWindow struct {
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+}
+
+// TrendTotals holds request and token counts for one window.
+type TrendTotals struct {
+	Requests int64 `json:"requests"`
+	Failed   int64 `json:"failed"`
+	Tokens   int64 `json:"tokens"`
+}
+
+// TrendLine compares today's totals with the same elapsed part of yesterday and of the
+// same weekday last week. Deltas are percentages and are omitted when the baseline is zero.
+type TrendLine struct {
+	Today     TrendTotals `json:"today"`
+	Yesterday TrendTotals `json:"yesterday"`
+	LastWeek  TrendTotals `json:"last_week"`
+
+	RequestsDeltaYesterday *float64 `json:"requests_delta_yesterday_pct,omitempty"`
+	RequestsDeltaLastWeek  *float64 `json:"requests_delta_last_week_pct,omitempty"`
+	TokensDeltaYesterday   *float64 `json:"tokens_delta_yesterday_pct,omitempty"`
+	TokensDeltaLastWeek    *float64 `json:"tokens_delta_last_week_pct,omitempty"`
+}
+
+// ModelTrend is a TrendLine for a single model.
+type ModelTrend struct {
+	Model string `json:"model"`
+	TrendLine
+}
+
+// TrendSummary answers "are we trending higher than usual right now?".
+type TrendSummary struct {
+	Timezone string `json:"timezone"`
+	Windows  struct {
+		Today     TrendWindow `json:"today"`
+		Yesterday TrendWindow `json:"yesterday"`
+		LastWeek  TrendWindow `json:"last_week"`
+	} `json:"windows"`
+	Overall TrendLine    `json:"overall"`
+	Models  []ModelTrend `json:"models"`
+}
+
+// TrendSummary compares today's usage up to now with the same portion of yesterday and of
+// the same weekday last week, in loc. Comparison windows end at the same local clock time
+// as now, so days that are shorter or longer because of a DST change still compare like
+// for like. A nil loc means time.Local.
+func (s *RequestStatistics) TrendSummary(now time.Time, loc *time.Location) TrendSummary {
+	if loc == nil {
+		loc = time.Local
+	}
+	now = now.In(loc)
+	windowFor := func(daysBack int) TrendWindow {
+		y, m, d := now.Date()
+		return TrendWindow{
+			From: time.Date(y, m, d-daysBack, 0, 0, 0, 0, loc),
+			To:   time.Date(y, m, d-daysBack, now.Hour(), now.Minute(), now.Second(), now.Nanosecond(), loc),
+		}
+	}
+
+	summary := TrendSummary{Timezone: loc.String(), Models: []ModelTrend{}}
+	summary.Windows.Today = windowFor(0)
+	summary.Windows.Yesterday = windowFor(1)
+	summary.Windows.LastWeek = windowFor(7)
+	if s == nil {
+		return summary
+	}
+
+	models := make(map[string]*TrendLine)
+	add := func(line *TrendLine, detail RequestDetail) {
+		var totals *TrendTotals
+		switch {
+		case summary.Windows.Today.contains(detail.Timestamp):
+			totals = &line.Today
+		case summary.Windows.Yesterday.contains(detail.Timestamp):
+			totals = &line.Yesterday
+		case summary.Windows.LastWeek.contains(detail.Timestamp):
+			totals = &line.LastWeek
+		default:
+			return
+		}
+		totals.Requests++
+		if detail.Failed {
+			totals.Failed++
+		}
+		totals.Tokens += detail.Tokens.TotalTokens
+	}
+
+	s.mu.RLock()
+	for _, stats := range s.apis {
+		for modelName, modelStatsValue := range stats.Models {
+			line, ok := models[modelName]
+			if !ok {
+				line = &TrendLine{}
+				models[modelName] = line
+			}
+			for _, detail := range modelStatsValue.Details {
+				add(&summary.Overall, detail)
+				add(line, detail)
+			}
+		}
+	}
+	s.mu.RUnlock()
+
+	summary.Overall.computeDeltas()
+	for modelName, line := range models {
+		if line.Today.Requests == 0 && line.Yesterday.Requests == 0 && line.LastWeek.Requests == 0 {
+			continue
+		}
+		line.computeDeltas()
+		summary.Models = append(summary.Models, ModelTrend{Model: modelName, TrendLine: *line})
+	}
+	sort.Slice(summary.Models, func(i, j int) bool {
+		a, b := summary.Models[i], summary.Models[j]
+		if a.Today.Requests != b.Today.Requests {
+			return a.Today.Requests > b.Today.Requests
+		}
+		if a.Today.Tokens != b.Today.Tokens {
+			return a.Today.Tokens > b.Today.Tokens
+		}
+		return a.Model < b.Model
+	})
+	if len(summary.Models) > trendTopModels {
+		summary.Models = summary.Models[:trendTopModels]
+	}
+	return summary
+}
+
+func (w TrendWindow) contains(ts time.Time) bool {
+	return !ts.Before(w.From) && !ts.After(w.To)
+}
+
+func (l *TrendLine) computeDeltas() {
+	l.RequestsDeltaYesterday = percentDelta(l.Today.Requests, l.Yesterday.Requests)
+	l.RequestsDeltaLastWeek = percentDelta(l.Today.Requests, l.LastWeek.Requests)
+	l.TokensDeltaYesterday = percentDelta(l.Today.Tokens, l.Yesterday.Tokens)
+	l.TokensDeltaLastWeek = percentDelta(l.Today.Tokens, l.LastWeek.Tokens)
+}
+
+func percentDelta(current, baseline int64) *float64 {
+	if baseline == 0 {
+		return nil
+	}
+	delta := float64(current-baseline) / float64(baseline) * 100
+	return &delta
+}
@@ -0,0 +1,96 @@
+package usage
+
+import (
+	"context"
+	"testing"
+	"time"
+	_ "time/tzdata"
+
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+)
+
+func recordAt(stats *RequestStatistics, model string, ts time.Time, tokens int64) {
+	stats.Record(context.Background(), coreusage.Record{
+		APIKey:      "key",
+		Model:       model,
+		RequestedAt: ts,
+		Detail:      coreusage.Detail{InputTokens: tokens},
+	})
+}
+
+func TestTrendSummaryJustAfterMidnight(t *testing.T) {
+	loc := time.FixedZone("UTC+2", 2*60*60)
+	now := time.Date(2025, 6, 10, 0, 30, 0, 0, loc)
+	stats := NewRequestStatistics()
+
+	recordAt(stats, "a", time.Date(2025, 6, 10, 0, 5, 0, 0, loc), 10)
+	recordAt(stats, "a", time.Date(2025, 6, 10, 0, 25, 0, 0, loc), 10)
+	recordAt(stats, "a", time.Date(2025, 6, 9, 0, 10, 0, 0, loc), 10)
+	// Late yesterday evening falls outside the first half hour of yesterday.
+	recordAt(stats, "a", time.Date(2025, 6, 9, 23, 50, 0, 0, loc), 10)
+	recordAt(stats, "a", time.Date(2025, 6, 3, 0, 29, 0, 0, loc), 10)
+	recordAt(stats, "a", time.Date(2025, 6, 3, 0, 31, 0, 0, loc), 10)
+
+	summary := stats.TrendSummary(now, loc)
+	overall := summary.Overall
+	if overall.Today.Requests != 2 || overall.Yesterday.Requests != 1 || overall.LastWeek.Requests != 1 {
+		t.Fatalf("requests today/yesterday/last week = %d/%d/%d, want 2/1/1",
+			overall.Today.Requests, overall.Yesterday.Requests, overall.LastWeek.Requests)
+	}
+	if overall.RequestsDeltaYesterday == nil || *overall.RequestsDeltaYesterday != 100 {
+		t.Fatalf("requests delta vs yesterday = %v, want 100", overall.RequestsDeltaYesterday)
+	}
+	if got := summary.Windows.Yesterday.To; !got.Equal(time.Date(2025, 6, 9, 0, 30, 0, 0, loc)) {
+		t.Fatalf("yesterday window ends at %v", got)
+	}
+}
+
+func TestTrendSummaryAcrossDSTChange(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatalf("load location: %v", err)
+	}
+	// 2025-03-09 is the spring-forward day: midnight to 10:00 is only nine hours long.
+	now := time.Date(2025, 3, 9, 10, 0, 0, 0, loc)
+	stats := NewRequestStatistics()
+
+	recordAt(stats, "a", time.Date(2025, 3, 9, 9, 0, 0, 0, loc), 5)
+	recordAt(stats, "a", time.Date(2025, 3, 8, 9, 30, 0, 0, loc), 5)
+	recordAt(stats, "a", time.Date(2025, 3, 8, 10, 30, 0, 0, loc), 5)
+	recordAt(stats, "a", time.Date(2025, 3, 2, 9, 59, 0, 0, loc), 5)
+
+	summary := stats.TrendSummary(now, loc)
+	if got := summary.Windows.Yesterday.To.Sub(summary.Windows.Yesterday.From); got != 10*time.Hour {
+		t.Fatalf("yesterday window = %v, want 10h of wall clock", got)
+	}
+	if got := summary.Windows.Today.To.Sub(summary.Windows.Today.From); got != 9*time.Hour {
+		t.Fatalf("today window = %v, want 9h", got)
+	}
+	overall := summary.Overall
+	if overall.Today.Requests != 1 || overall.Yesterday.Requests != 1 || overall.LastWeek.Requests != 1 {
+		t.Fatalf("requests today/yesterday/last week = %d/%d/%d, want 1/1/1",
+			overall.Today.Requests, overall.Yesterday.Requests, overall.LastWeek.Requests)
+	}
+}
+
+func TestTrendSummaryTopModels(t *testing.T) {
+	loc := time.UTC
+	now := time.Date(2025, 6, 10, 12, 0, 0, 0, loc)
+	stats := NewRequestStatistics()
+	models := []string{"m1", "m2", "m3", "m4", "m5", "m6"}
+	for i, model := range models {
+		for j := 0; j <= i; j++ {
+			recordAt(stats, model, now.Add(-time.Duration(j+1)*time.Minute), 1)
+		}
+	}
+	summary := stats.TrendSummary(now, loc)
+	if len(summary.Models) != trendTopModels {
+		t.Fatalf("models = %d, want %d", len(summary.Models), trendTopModels)
+	}
+	if summary.Models[0].Model != "m6" || summary.Models[4].Model != "m2" {
+		t.Fatalf("unexpected model order: %+v", summary.Models)
+	}
+	if summary.Models[0].RequestsDeltaYesterday != nil {
+		t.Fatalf("delta should be omitted without a baseline")
+	}
+}
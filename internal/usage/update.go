@@ -0,0 +1,141 @@
+package usage
+
+import (
+	"context"
+
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+	log "github.com/sirupsen/logrus"
+)
+
+// applyUpdate raises the token counts of the recorded request identified by record.RequestID
+// to those carried by a late follow-up record. Counts never decrease, and an update whose
+// request is not held (for example because it was recorded while statistics were disabled)
+// is dropped rather than counted as a new request.
+func (s *RequestStatistics) applyUpdate(ctx context.Context, record coreusage.Record) {
+	if record.RequestID == "" {
+		return
+	}
+	statsKey := record.APIKey
+	if statsKey == "" {
+		statsKey = resolveAPIIdentifier(ctx, record)
+	}
+	if statsKey == coreusage.SystemAPIKey {
+		return
+	}
+	modelName := record.Model
+	if modelName == "" {
+		modelName = "unknown"
+	}
+	late := normaliseDetail(record.Detail)
+
+	s.mu.Lock()
+	if updated, archived := s.updateArchived(record.RequestID, late); archived {
+		sink := s.sink
+		s.mu.Unlock()
+		if updated != nil {
+			persistUpdate(sink, *updated)
+		}
+		return
+	}
+	stats, ok := s.apis[statsKey]
+	if !ok {
+		s.mu.Unlock()
+		return
+	}
+	modelStatsValue, ok := stats.Models[modelName]
+	if !ok {
+		s.mu.Unlock()
+		return
+	}
+	var detail *RequestDetail
+	// Updates follow their request closely, so search from the newest detail.
+	for i := len(modelStatsValue.Details) - 1; i >= 0; i-- {
+		if modelStatsValue.Details[i].RequestID == record.RequestID {
+			detail = &modelStatsValue.Details[i]
+			break
+		}
+	}
+	if detail == nil {
+		s.mu.Unlock()
+		log.Debugf("usage: dropping token update for unknown request %s", record.RequestID)
+		return
+	}
+	merged := MergeTokenStats(detail.Provider, detail.Tokens, late)
+	if merged == detail.Tokens {
+		s.mu.Unlock()
+		return
+	}
+	tokenDelta := merged.TotalTokens - detail.Tokens.TotalTokens
+	cost := EstimateCost(detail.Provider, modelName, merged)
+	costDelta := cost - detail.CostMicrodollars
+	detail.Tokens = merged
+	detail.CostMicrodollars = cost
+
+	modelStatsValue.TotalTokens += tokenDelta
+	modelStatsValue.TotalCost += costDelta
+	stats.TotalTokens += tokenDelta
+	stats.TotalCost += costDelta
+	s.totalTokens += tokenDelta
+	s.totalCost += costDelta
+	s.tokensByDay[detail.Timestamp.Format("2006-01-02")] += tokenDelta
+	s.tokensByHour[detail.Timestamp.Hour()] += tokenDelta
+	s.trackQuotaTokens(statsKey, detail.Timestamp, tokenDelta)
+
+	updated := FlatRecord{APIKey: statsKey, Model: modelName, RequestDetail: *detail}
+	sink := s.sink
+	s.mu.Unlock()
+
+	persistUpdate(sink, updated)
+}
+
+// updateArchived raises the token counts of an archived request, reporting whether the
+// request is archived and returning the updated record when its counts changed. Archived
+// requests are outside every aggregate but still count toward quotas. Callers hold s.mu.
+func (s *RequestStatistics) updateArchived(requestID string, late TokenStats) (*FlatRecord, bool) {
+	i, ok := s.archiveIndex[requestID]
+	if !ok {
+		return nil, false
+	}
+	record := &s.archive[i]
+	merged := MergeTokenStats(record.Provider, record.Tokens, late)
+	if merged == record.Tokens {
+		return nil, true
+	}
+	s.trackQuotaTokens(record.APIKey, record.Timestamp, merged.TotalTokens-record.Tokens.TotalTokens)
+	record.Tokens = merged
+	record.CostMicrodollars = EstimateCost(record.Provider, record.Model, merged)
+	updated := *record
+	return &updated, true
+}
+
+// persistUpdate appends the updated record to sink so reloading the store yields the late
+// counts.
+func persistUpdate(sink RecordSink, updated FlatRecord) {
+	if sink == nil {
+		return
+	}
+	if err := sink.Append(updated); err != nil {
+		log.Warnf("usage: failed to persist token update for request %s: %v", updated.RequestID, err)
+	}
+}
+
+// MergeTokenStats combines two token reports for the same request, such as the partial and
+// final usage events of a stream, keeping the larger of each count. The total is rebuilt
+// from the merged counts under the provider's conventions, since a later event may report
+// only the output it adds; it never drops below a reported total, which some providers
+// extend with tokens outside these fields.
+func MergeTokenStats(provider string, a, b TokenStats) TokenStats {
+	merged := TokenStats{
+		InputTokens:     max(a.InputTokens, b.InputTokens),
+		OutputTokens:    max(a.OutputTokens, b.OutputTokens),
+		ReasoningTokens: max(a.ReasoningTokens, b.ReasoningTokens),
+		CachedTokens:    max(a.CachedTokens, b.CachedTokens),
+		TotalTokens:     max(a.TotalTokens, b.TotalTokens),
+	}
+	total := merged.InputTokens + merged.OutputTokens
+	if _, reasoningSeparate := tokenConventions(provider, merged); reasoningSeparate {
+		total += merged.ReasoningTokens
+	}
+	merged.TotalTokens = max(merged.TotalTokens, total)
+	return merged
+}
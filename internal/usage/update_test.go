@@ -0,0 +1,124 @@
+package usage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+)
+
+func TestRecordAppliesLateTokenUpdates(t *testing.T) {
+	ts := time.Date(2026, 4, 1, 10, 0, 0, 0, time.UTC)
+	record := func(id string, update bool, input, output int64) coreusage.Record {
+		return coreusage.Record{
+			APIKey:      "k",
+			Model:       "m",
+			RequestedAt: ts,
+			RequestID:   id,
+			Update:      update,
+			Detail:      coreusage.Detail{InputTokens: input, OutputTokens: output},
+		}
+	}
+	tokens := func(stats *RequestStatistics) (int64, int64) {
+		snapshot := stats.Snapshot()
+		return snapshot.TotalRequests, snapshot.TotalTokens
+	}
+
+	t.Run("initial zero then final", func(t *testing.T) {
+		stats := NewRequestStatistics()
+		stats.Record(context.Background(), record("r1", false, 0, 0))
+		stats.Record(context.Background(), record("r1", true, 10, 5))
+		if requests, total := tokens(stats); requests != 1 || total != 15 {
+			t.Fatalf("requests=%d tokens=%d, want 1 and 15", requests, total)
+		}
+		if used := stats.QuotaUsage("k", ts); used.TokensToday != 15 || used.RequestsToday != 1 {
+			t.Fatalf("quota counters not updated: %+v", used)
+		}
+	})
+
+	t.Run("final only", func(t *testing.T) {
+		stats := NewRequestStatistics()
+		stats.Record(context.Background(), record("r1", false, 10, 5))
+		if requests, total := tokens(stats); requests != 1 || total != 15 {
+			t.Fatalf("requests=%d tokens=%d, want 1 and 15", requests, total)
+		}
+	})
+
+	t.Run("duplicate and smaller finals", func(t *testing.T) {
+		stats := NewRequestStatistics()
+		stats.Record(context.Background(), record("r1", false, 10, 0))
+		stats.Record(context.Background(), record("r1", true, 10, 5))
+		stats.Record(context.Background(), record("r1", true, 10, 5))
+		stats.Record(context.Background(), record("r1", true, 3, 1))
+		if requests, total := tokens(stats); requests != 1 || total != 15 {
+			t.Fatalf("requests=%d tokens=%d, want 1 and 15", requests, total)
+		}
+	})
+
+	t.Run("update for an unknown request", func(t *testing.T) {
+		stats := NewRequestStatistics()
+		stats.Record(context.Background(), record("r1", false, 10, 0))
+		stats.Record(context.Background(), record("r2", true, 50, 50))
+		if requests, total := tokens(stats); requests != 1 || total != 10 {
+			t.Fatalf("requests=%d tokens=%d, want 1 and 10", requests, total)
+		}
+	})
+	t.Run("archived request is persisted", func(t *testing.T) {
+		cfg := config.UsageStore{Backend: "jsonl", Dir: t.TempDir()}
+		stats := NewRequestStatistics()
+		persistence, err := OpenUsageStore(cfg, stats)
+		if err != nil {
+			t.Fatalf("open: %v", err)
+		}
+		stats.Record(context.Background(), record("r1", false, 10, 1))
+		marker := ResetMarker{At: ts.Add(time.Minute)}
+		if err = persistence.AppendReset(marker); err != nil {
+			t.Fatalf("append reset: %v", err)
+		}
+		stats.Reset(marker)
+		stats.Record(context.Background(), record("r1", true, 10, 5))
+		if used := stats.QuotaUsage("k", ts); used.TokensToday != 15 {
+			t.Fatalf("quota tokens = %d, want 15", used.TokensToday)
+		}
+		if err = persistence.Close(); err != nil {
+			t.Fatalf("close: %v", err)
+		}
+
+		reloaded := NewRequestStatistics()
+		reopened, err := OpenUsageStore(cfg, reloaded)
+		if err != nil {
+			t.Fatalf("reopen: %v", err)
+		}
+		defer func() { _ = reopened.Close() }()
+		archived := reloaded.ArchivedRecords(time.Time{}, time.Time{})
+		if len(archived) != 1 || archived[0].Tokens.TotalTokens != 15 {
+			t.Fatalf("archived after reload = %+v, want one request with 15 tokens", archived)
+		}
+	})
+}
+
+func TestMergeTokenStatsRebuildsTotal(t *testing.T) {
+	cases := []struct {
+		name     string
+		provider string
+		a, b     TokenStats
+		want     int64
+	}{
+		{"claude delta reports only output", "claude",
+			TokenStats{InputTokens: 1000, OutputTokens: 1, CachedTokens: 200, TotalTokens: 1001},
+			TokenStats{OutputTokens: 87, TotalTokens: 87}, 1087},
+		{"gemini reasoning is separate", "gemini",
+			TokenStats{InputTokens: 10, OutputTokens: 2, TotalTokens: 12},
+			TokenStats{InputTokens: 10, OutputTokens: 5, ReasoningTokens: 7, TotalTokens: 22}, 22},
+		{"reported total above the fields is kept", "openai",
+			TokenStats{InputTokens: 10, OutputTokens: 5, TotalTokens: 20},
+			TokenStats{InputTokens: 10, OutputTokens: 6, TotalTokens: 16}, 20},
+	}
+	for _, tc := range cases {
+		if got := MergeTokenStats(tc.provider, tc.a, tc.b).TotalTokens; got != tc.want {
+			t.Errorf("%s: total = %d, want %d", tc.name, got, tc.want)
+		}
+	}
+}
@@ -33,6 +33,15 @@ func BuildConfigChangeDetails(oldCfg, newCfg *config.Config) []string {
 	if oldCfg.UsageStatisticsEnabled != newCfg.UsageStatisticsEnabled {
 		changes = append(changes, fmt.Sprintf("usage-statistics-enabled: %t -> %t", oldCfg.UsageStatisticsEnabled, newCfg.UsageStatisticsEnabled))
 	}
+	if oldCfg.UsageStatisticsHorizonDays != newCfg.UsageStatisticsHorizonDays {
+		changes = append(changes, fmt.Sprintf("usage-statistics-horizon-days: %d -> %d", oldCfg.UsageStatisticsHorizonDays, newCfg.UsageStatisticsHorizonDays))
+	}
+	if oldCfg.UsageClientMetadata != newCfg.UsageClientMetadata {
+		changes = append(changes, fmt.Sprintf("usage-client-metadata: %s -> %s", oldCfg.UsageClientMetadata, newCfg.UsageClientMetadata))
+	}
+	if oldCfg.CredentialHealth != newCfg.CredentialHealth {
+		changes = append(changes, fmt.Sprintf("credential-health: %+v -> %+v", oldCfg.CredentialHealth, newCfg.CredentialHealth))
+	}
 	if oldCfg.DisableCooling != newCfg.DisableCooling {
 		changes = append(changes, fmt.Sprintf("disable-cooling: %t -> %t", oldCfg.DisableCooling, newCfg.DisableCooling))
 	}
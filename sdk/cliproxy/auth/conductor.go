@@ -21,6 +21,7 @@ import (
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/thinking"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -2131,11 +2132,19 @@ func (m *Manager) refreshAuth(ctx context.Context, id string) {
 		return
 	}
 	cloned := auth.Clone()
+	requestedAt := time.Now()
 	updated, err := exec.Refresh(ctx, cloned)
 	if err != nil && errors.Is(err, context.Canceled) {
 		log.Debugf("refresh canceled for %s, %s", auth.Provider, auth.ID)
 		return
 	}
+	usage.PublishSystemRecord(ctx, usage.PurposeTokenRefresh, usage.Record{
+		Provider:    auth.Provider,
+		AuthID:      auth.ID,
+		AuthIndex:   auth.EnsureIndex(),
+		RequestedAt: requestedAt,
+		Failed:      err != nil,
+	})
 	log.Debugf("refreshed %s, %s, %v", auth.Provider, auth.ID, err)
 	now := time.Now()
 	if err != nil {
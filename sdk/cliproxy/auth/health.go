@@ -0,0 +1,36 @@
+package auth
+
+import "sync/atomic"
+
+// HealthChecker reports whether the credential identified by authIndex is currently failing
+// too often to serve model. Within a priority tier, unhealthy credentials are only picked when
+// every available credential of the tier is unhealthy.
+type HealthChecker func(authIndex, model string) bool
+
+var healthChecker atomic.Pointer[HealthChecker]
+
+// SetHealthChecker installs checker for credential selection. A nil checker disables
+// health-based selection.
+func SetHealthChecker(checker HealthChecker) {
+	if checker == nil {
+		healthChecker.Store(nil)
+		return
+	}
+	healthChecker.Store(&checker)
+}
+
+func isAuthUnhealthy(auth *Auth, model string) bool {
+	checker := healthChecker.Load()
+	if checker == nil || auth == nil {
+		return false
+	}
+	index := auth.Index
+	if !auth.indexAssigned || index == "" {
+		// Pick may run concurrently on shared auths, so derive the index without storing it.
+		index = stableAuthIndex(auth.indexSeed())
+	}
+	if index == "" {
+		return false
+	}
+	return (*checker)(index, model)
+}
@@ -121,11 +121,16 @@ func authPriority(auth *Auth) int {
 
 func collectAvailableByPriority(auths []*Auth, model string, now time.Time) (available map[int][]*Auth, cooldownCount int, earliest time.Time) {
 	available = make(map[int][]*Auth)
+	unhealthy := make(map[int][]*Auth)
 	for i := 0; i < len(auths); i++ {
 		candidate := auths[i]
 		blocked, reason, next := isAuthBlockedForModel(candidate, model, now)
 		if !blocked {
 			priority := authPriority(candidate)
+			if isAuthUnhealthy(candidate, model) {
+				unhealthy[priority] = append(unhealthy[priority], candidate)
+				continue
+			}
 			available[priority] = append(available[priority], candidate)
 			continue
 		}
@@ -136,6 +141,12 @@ func collectAvailableByPriority(auths []*Auth, model string, now time.Time) (ava
 			}
 		}
 	}
+	// Unhealthy credentials keep a priority tier usable when every credential in it is unhealthy.
+	for priority, candidates := range unhealthy {
+		if _, ok := available[priority]; !ok {
+			available[priority] = candidates
+		}
+	}
 	return available, cooldownCount, earliest
 }
 
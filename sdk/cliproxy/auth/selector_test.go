@@ -175,3 +175,23 @@ func TestRoundRobinSelectorPick_Concurrent(t *testing.T) {
 	default:
 	}
 }
+
+func TestSelectorPick_SkipsUnhealthyAuths(t *testing.T) {
+	// Not parallel: the health checker is global.
+	unhealthyIndex := (&Auth{ID: "a"}).EnsureIndex()
+	SetHealthChecker(func(authIndex, model string) bool { return authIndex == unhealthyIndex && model == "gemini-2.5-pro" })
+	t.Cleanup(func() { SetHealthChecker(nil) })
+
+	selector := &FillFirstSelector{}
+	auths := []*Auth{{ID: "a"}, {ID: "b"}}
+	got, err := selector.Pick(context.Background(), "gemini", "gemini-2.5-pro", cliproxyexecutor.Options{}, auths)
+	if err != nil || got.ID != "b" {
+		t.Fatalf("Pick() = %v, %v; want the healthy auth b", got, err)
+	}
+	if got, _ = selector.Pick(context.Background(), "gemini", "gemini-2.5-flash", cliproxyexecutor.Options{}, auths); got.ID != "a" {
+		t.Fatalf("Pick() for another model = %q, want a", got.ID)
+	}
+	if got, err = selector.Pick(context.Background(), "gemini", "gemini-2.5-pro", cliproxyexecutor.Options{}, auths[:1]); err != nil || got.ID != "a" {
+		t.Fatalf("Pick() with only unhealthy auths = %v, %v; want a", got, err)
+	}
+}
@@ -141,20 +141,9 @@ func (a *Auth) EnsureIndex() string {
 		return a.Index
 	}
 
-	seed := strings.TrimSpace(a.FileName)
-	if seed != "" {
-		seed = "file:" + seed
-	} else if a.Attributes != nil {
-		if apiKey := strings.TrimSpace(a.Attributes["api_key"]); apiKey != "" {
-			seed = "api_key:" + apiKey
-		}
-	}
+	seed := a.indexSeed()
 	if seed == "" {
-		if id := strings.TrimSpace(a.ID); id != "" {
-			seed = "id:" + id
-		} else {
-			return ""
-		}
+		return ""
 	}
 
 	idx := stableAuthIndex(seed)
@@ -163,6 +152,22 @@ func (a *Auth) EnsureIndex() string {
 	return idx
 }
 
+// indexSeed returns the value the stable index is derived from, or "" when there is none.
+func (a *Auth) indexSeed() string {
+	if seed := strings.TrimSpace(a.FileName); seed != "" {
+		return "file:" + seed
+	}
+	if a.Attributes != nil {
+		if apiKey := strings.TrimSpace(a.Attributes["api_key"]); apiKey != "" {
+			return "api_key:" + apiKey
+		}
+	}
+	if id := strings.TrimSpace(a.ID); id != "" {
+		return "id:" + id
+	}
+	return ""
+}
+
 // Clone duplicates a model state including nested error details.
 func (m *ModelState) Clone() *ModelState {
 	if m == nil {
@@ -656,7 +656,9 @@ func (s *Service) Shutdown(ctx context.Context) error {
 			}
 		}
 
-		usage.StopDefault()
+		flushCtx, cancelFlush := context.WithTimeout(ctx, 5*time.Second)
+		_ = usage.FlushDefault(flushCtx)
+		cancelFlush()
 	})
 	return shutdownErr
 }
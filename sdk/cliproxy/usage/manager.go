@@ -19,8 +19,48 @@ type Record struct {
 	RequestedAt time.Time
 	Failed      bool
 	Detail      Detail
+	// StatusCode is the upstream HTTP status of a failed request, when known.
+	StatusCode int
+	// ErrorType classifies a failed request (see the ErrorType constants).
+	ErrorType string
+	// RequestID uniquely identifies the record. When empty the statistics store assigns one,
+	// so identical concurrent requests are still counted separately.
+	RequestID string
+	// Latency is the time from issuing the upstream request to recording its usage.
+	// Zero means the timing is unknown.
+	Latency time.Duration
+	// Purpose classifies requests the proxy issues on its own behalf (see SystemAPIKey).
+	// It is empty for client traffic.
+	Purpose string
+	// Update marks a follow-up for the request already published under RequestID, carrying
+	// token counts that arrived late (for example in the final event of a stream). Detail
+	// holds the complete counts, not a delta. Updates are delivered only to plugins that
+	// implement UpdateHandler; HandleUsage sees each request once.
+	Update bool
 }
 
+// SystemAPIKey is the reserved API key attributed to upstream requests the proxy
+// makes on its own behalf rather than for a client.
+const SystemAPIKey = "__system"
+
+// Purposes recorded for system-initiated requests.
+const (
+	PurposeModelRefresh = "model_refresh"
+	PurposeTokenRefresh = "token_refresh"
+	PurposeProbe        = "probe"
+)
+
+// Error classes recorded for failed requests.
+const (
+	ErrorTypeRateLimit      = "rate_limit"
+	ErrorTypeAuth           = "auth"
+	ErrorTypeTimeout        = "timeout"
+	ErrorTypeCanceled       = "canceled"
+	ErrorTypeUpstream       = "upstream"
+	ErrorTypeInvalidRequest = "invalid_request"
+	ErrorTypeOther          = "other"
+)
+
 // Detail holds the token usage breakdown.
 type Detail struct {
 	InputTokens     int64
@@ -30,14 +70,30 @@ type Detail struct {
 	TotalTokens     int64
 }
 
-// Plugin consumes usage records emitted by the proxy runtime.
+// Plugin consumes usage records emitted by the proxy runtime. Each request is delivered to
+// HandleUsage once.
 type Plugin interface {
 	HandleUsage(ctx context.Context, record Record)
 }
 
+// UpdateHandler is implemented by plugins that also want the late token counts of requests
+// they already handled. HandleUsageUpdate receives records with Update set, carrying the
+// complete counts for the request published under the same RequestID.
+type UpdateHandler interface {
+	HandleUsageUpdate(ctx context.Context, record Record)
+}
+
+// Gate is implemented by plugins that decide whether to take a record at publish time.
+// A record accepted by the gate is delivered even if the plugin stops accepting
+// records before the queue reaches it, so toggling intake never drops queued work.
+type Gate interface {
+	AcceptUsage(ctx context.Context, record Record) bool
+}
+
 type queueItem struct {
-	ctx    context.Context
-	record Record
+	ctx     context.Context
+	record  Record
+	plugins []Plugin
 }
 
 // Manager maintains a queue of usage records and delivers them to registered plugins.
@@ -45,6 +101,8 @@ type Manager struct {
 	once     sync.Once
 	stopOnce sync.Once
 	cancel   context.CancelFunc
+	// done is closed once the dispatcher has drained the queue after Stop.
+	done chan struct{}
 
 	mu     sync.Mutex
 	cond   *sync.Cond
@@ -73,7 +131,14 @@ func (m *Manager) Start(ctx context.Context) {
 		}
 		var workerCtx context.Context
 		workerCtx, m.cancel = context.WithCancel(ctx)
-		go m.run(workerCtx)
+		done := make(chan struct{})
+		m.mu.Lock()
+		m.done = done
+		m.mu.Unlock()
+		go func() {
+			defer close(done)
+			m.run(workerCtx)
+		}()
 	})
 }
 
@@ -93,6 +158,32 @@ func (m *Manager) Stop() {
 	})
 }
 
+// Flush stops the dispatcher and waits until every queued record has been delivered or
+// ctx is done. When ctx ends first, the number of undelivered records is logged and
+// ctx.Err() is returned; the dispatcher keeps draining in the background.
+func (m *Manager) Flush(ctx context.Context) error {
+	if m == nil {
+		return nil
+	}
+	m.Stop()
+	m.mu.Lock()
+	done := m.done
+	m.mu.Unlock()
+	if done == nil {
+		return nil
+	}
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		m.mu.Lock()
+		pending := len(m.queue)
+		m.mu.Unlock()
+		log.Warnf("usage: shutdown deadline reached with %d records undelivered", pending)
+		return ctx.Err()
+	}
+}
+
 // Register appends a plugin to the delivery list.
 func (m *Manager) Register(plugin Plugin) {
 	if m == nil || plugin == nil {
@@ -112,11 +203,21 @@ func (m *Manager) Publish(ctx context.Context, record Record) {
 	// ensure worker is running even if Start was not called explicitly
 	m.Start(context.Background())
 	m.mu.Lock()
+	closed := m.closed
+	m.mu.Unlock()
+	if closed {
+		return
+	}
+	plugins := m.acceptingPlugins(ctx, record)
+	if len(plugins) == 0 {
+		return
+	}
+	m.mu.Lock()
 	if m.closed {
 		m.mu.Unlock()
 		return
 	}
-	m.queue = append(m.queue, queueItem{ctx: ctx, record: record})
+	m.queue = append(m.queue, queueItem{ctx: ctx, record: record, plugins: plugins})
 	m.mu.Unlock()
 	m.cond.Signal()
 }
@@ -138,18 +239,29 @@ func (m *Manager) run(ctx context.Context) {
 	}
 }
 
-func (m *Manager) dispatch(item queueItem) {
+// acceptingPlugins returns the registered plugins that accept record, consulting Gate
+// implementations at publish time. Updates only go to UpdateHandler implementations.
+func (m *Manager) acceptingPlugins(ctx context.Context, record Record) []Plugin {
 	m.pluginsMu.RLock()
-	plugins := make([]Plugin, len(m.plugins))
-	copy(plugins, m.plugins)
-	m.pluginsMu.RUnlock()
-	if len(plugins) == 0 {
-		return
-	}
-	for _, plugin := range plugins {
+	defer m.pluginsMu.RUnlock()
+	plugins := make([]Plugin, 0, len(m.plugins))
+	for _, plugin := range m.plugins {
 		if plugin == nil {
 			continue
 		}
+		if _, ok := plugin.(UpdateHandler); record.Update && !ok {
+			continue
+		}
+		if gate, ok := plugin.(Gate); ok && !gate.AcceptUsage(ctx, record) {
+			continue
+		}
+		plugins = append(plugins, plugin)
+	}
+	return plugins
+}
+
+func (m *Manager) dispatch(item queueItem) {
+	for _, plugin := range item.plugins {
 		safeInvoke(plugin, item.ctx, item.record)
 	}
 }
@@ -160,6 +272,10 @@ func safeInvoke(plugin Plugin, ctx context.Context, record Record) {
 			log.Errorf("usage: plugin panic recovered: %v", r)
 		}
 	}()
+	if record.Update {
+		plugin.(UpdateHandler).HandleUsageUpdate(ctx, record)
+		return
+	}
 	plugin.HandleUsage(ctx, record)
 }
 
@@ -174,8 +290,22 @@ func RegisterPlugin(plugin Plugin) { DefaultManager().Register(plugin) }
 // PublishRecord publishes a record using the default manager.
 func PublishRecord(ctx context.Context, record Record) { DefaultManager().Publish(ctx, record) }
 
+// PublishSystemRecord publishes a record for a system-initiated request, attributing it
+// to SystemAPIKey with the given purpose.
+func PublishSystemRecord(ctx context.Context, purpose string, record Record) {
+	record.APIKey = SystemAPIKey
+	record.Purpose = purpose
+	if record.RequestedAt.IsZero() {
+		record.RequestedAt = time.Now()
+	}
+	PublishRecord(ctx, record)
+}
+
 // StartDefault starts the default manager's dispatcher.
 func StartDefault(ctx context.Context) { DefaultManager().Start(ctx) }
 
 // StopDefault stops the default manager's dispatcher.
 func StopDefault() { DefaultManager().Stop() }
+
+// FlushDefault stops the default manager and waits for queued records to be delivered.
+func FlushDefault(ctx context.Context) error { return DefaultManager().Flush(ctx) }
@@ -0,0 +1,149 @@
+package usage
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type gatedPlugin struct {
+	enabled  atomic.Bool
+	accepted atomic.Int64
+	handled  atomic.Int64
+}
+
+func (p *gatedPlugin) AcceptUsage(context.Context, Record) bool {
+	if !p.enabled.Load() {
+		return false
+	}
+	p.accepted.Add(1)
+	return true
+}
+
+func (p *gatedPlugin) HandleUsage(context.Context, Record) {
+	p.handled.Add(1)
+}
+
+func TestManagerDeliversRecordsAcceptedBeforeDisable(t *testing.T) {
+	m := NewManager(0)
+	plugin := &gatedPlugin{}
+	plugin.enabled.Store(true)
+	m.Register(plugin)
+	m.Start(context.Background())
+
+	done := make(chan struct{})
+	go func() {
+		for i := 0; ; i++ {
+			select {
+			case <-done:
+				return
+			default:
+				plugin.enabled.Store(i%2 == 1)
+				time.Sleep(100 * time.Microsecond)
+			}
+		}
+	}()
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 2000; j++ {
+				m.Publish(context.Background(), Record{Model: "m"})
+			}
+		}()
+	}
+	wg.Wait()
+	close(done)
+	m.Stop()
+
+	deadline := time.Now().Add(10 * time.Second)
+	for plugin.handled.Load() != plugin.accepted.Load() && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if accepted, handled := plugin.accepted.Load(), plugin.handled.Load(); accepted != handled {
+		t.Fatalf("handled %d records, want exactly the %d accepted", handled, accepted)
+	}
+	if plugin.accepted.Load() == 0 {
+		t.Fatal("no records were accepted")
+	}
+}
+
+type slowPlugin struct {
+	delay   time.Duration
+	handled atomic.Int64
+}
+
+func (p *slowPlugin) HandleUsage(context.Context, Record) {
+	time.Sleep(p.delay)
+	p.handled.Add(1)
+}
+
+func TestManagerFlushDeliversQueuedRecords(t *testing.T) {
+	m := NewManager(0)
+	plugin := &slowPlugin{delay: time.Millisecond}
+	m.Register(plugin)
+	for i := 0; i < 50; i++ {
+		m.Publish(context.Background(), Record{Model: "m"})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := m.Flush(ctx); err != nil {
+		t.Fatalf("flush: %v", err)
+	}
+	if handled := plugin.handled.Load(); handled != 50 {
+		t.Fatalf("handled %d records before flush returned, want 50", handled)
+	}
+	m.Publish(context.Background(), Record{Model: "m"})
+	if handled := plugin.handled.Load(); handled != 50 {
+		t.Fatalf("record published after flush was delivered")
+	}
+}
+
+func TestManagerFlushHonoursDeadline(t *testing.T) {
+	m := NewManager(0)
+	m.Register(&slowPlugin{delay: 20 * time.Millisecond})
+	for i := 0; i < 20; i++ {
+		m.Publish(context.Background(), Record{Model: "m"})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := m.Flush(ctx); err == nil {
+		t.Fatal("expected flush to give up at the deadline")
+	}
+}
+
+type updatePlugin struct {
+	slowPlugin
+	updates atomic.Int64
+}
+
+func (p *updatePlugin) HandleUsageUpdate(context.Context, Record) {
+	p.updates.Add(1)
+}
+
+func TestManagerDeliversUpdatesOnlyToUpdateHandlers(t *testing.T) {
+	m := NewManager(0)
+	plain := &slowPlugin{}
+	updates := &updatePlugin{}
+	m.Register(plain)
+	m.Register(updates)
+	m.Publish(context.Background(), Record{Model: "m", RequestID: "r"})
+	m.Publish(context.Background(), Record{Model: "m", RequestID: "r", Update: true})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := m.Flush(ctx); err != nil {
+		t.Fatalf("flush: %v", err)
+	}
+	if handled := plain.handled.Load(); handled != 1 {
+		t.Fatalf("plain plugin handled %d records, want the request once", handled)
+	}
+	if handled, got := updates.handled.Load(), updates.updates.Load(); handled != 1 || got != 1 {
+		t.Fatalf("update handler got %d records and %d updates, want 1 of each", handled, got)
+	}
+}